@@ -0,0 +1,312 @@
+/* Chrome DevTools Protocol client for sessions started through selenium, see
+https://chromedevtools.github.io/devtools-protocol/ */
+package cdp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"se/selenium"
+	"se/websocket"
+	"sync"
+)
+
+/* Returned by Send once the connection is closed. */
+var ErrClosed = errors.New("cdp: connection closed")
+
+/* Error reply of a command. */
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data"`
+}
+
+func (e *Error) Error() string {
+	if e.Data != "" {
+		return fmt.Sprintf("cdp: %s (%d): %s", e.Message, e.Code, e.Data)
+	}
+	return fmt.Sprintf("cdp: %s (%d)", e.Message, e.Code)
+}
+
+/* Event sent by the browser, e.g. "Network.requestWillBeSent". */
+type Event struct {
+	Method string
+	Params json.RawMessage
+}
+
+type message struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    interface{}     `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *Error          `json:"error,omitempty"`
+}
+
+type incoming struct {
+	ID        int64           `json:"id"`
+	SessionID string          `json:"sessionId"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params"`
+	Result    json.RawMessage `json:"result"`
+	Error     *Error          `json:"error"`
+}
+
+/* Size of the event channels, events are dropped for subscribers that fall behind. */
+const eventBuffer = 256
+
+type Client struct {
+	conn    *websocket.Conn
+	session string // Flat session id of the page target, empty when connected to a page directly.
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *incoming
+	subs    map[string][]chan Event
+	closed  bool
+	done    chan struct{}
+}
+
+/* Connect to the browser of a selenium session. */
+func ConnectDriver(wd selenium.WebDriver) (*Client, error) {
+	caps, err := wd.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+	return Connect(caps)
+}
+
+/* Connect using the session capabilities: "se:cdp" (Selenium Grid 4, a browser endpoint) or
+"goog:chromeOptions"/"ms:edgeOptions" debuggerAddress (local chromedriver). Both end up
+talking to the first page target. */
+func Connect(caps selenium.Capabilities) (*Client, error) {
+	if endpoint, ok := caps["se:cdp"].(string); ok && endpoint != "" {
+		c, err := Dial(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if err = c.attachToPage(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	}
+
+	for _, key := range []string{"goog:chromeOptions", "ms:edgeOptions"} {
+		options, _ := caps[key].(map[string]interface{})
+		if address, ok := options["debuggerAddress"].(string); ok && address != "" {
+			endpoint, err := pageEndpoint(address)
+			if err != nil {
+				return nil, err
+			}
+			return Dial(endpoint)
+		}
+	}
+
+	return nil, errors.New("cdp: the session reports neither se:cdp nor a debuggerAddress")
+}
+
+/* Connect to a DevTools WebSocket endpoint. Page endpoints (ws://host/devtools/page/<id>) take
+page commands directly, browser endpoints need AttachToPage first. */
+func Dial(endpoint string) (*Client, error) {
+	conn, err := websocket.Dial(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:    conn,
+		pending: map[int64]chan *incoming{},
+		subs:    map[string][]chan Event{},
+		done:    make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+/* WebSocket endpoint of the first page target listed by the DevTools HTTP server. */
+func pageEndpoint(address string) (string, error) {
+	res, err := http.Get("http://" + address + "/json/list")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var targets []struct {
+		Type                 string `json:"type"`
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err = json.Unmarshal(data, &targets); err != nil {
+		return "", fmt.Errorf("cdp: unexpected /json/list reply: %s", err)
+	}
+	for _, t := range targets {
+		if t.Type == "page" && t.WebSocketDebuggerURL != "" {
+			return t.WebSocketDebuggerURL, nil
+		}
+	}
+	return "", fmt.Errorf("cdp: no page target at %s", address)
+}
+
+/* Attach to the first page target over a browser endpoint; later commands go to its session. */
+func (c *Client) attachToPage() error {
+	var targets struct {
+		TargetInfos []struct {
+			TargetID string `json:"targetId"`
+			Type     string `json:"type"`
+		} `json:"targetInfos"`
+	}
+	if err := c.Call("Target.getTargets", nil, &targets); err != nil {
+		return err
+	}
+
+	for _, t := range targets.TargetInfos {
+		if t.Type != "page" {
+			continue
+		}
+		var attached struct {
+			SessionID string `json:"sessionId"`
+		}
+		params := map[string]interface{}{"targetId": t.TargetID, "flatten": true}
+		if err := c.Call("Target.attachToTarget", params, &attached); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.session = attached.SessionID
+		c.mu.Unlock()
+		return nil
+	}
+	return errors.New("cdp: no page target to attach to")
+}
+
+/* Send a command and wait for its result. */
+func (c *Client) Send(method string, params interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	c.nextID++
+	msg := &message{ID: c.nextID, SessionID: c.session, Method: method, Params: params}
+	reply := make(chan *incoming, 1)
+	c.pending[msg.ID] = reply
+	c.mu.Unlock()
+
+	data, err := json.Marshal(msg)
+	if err == nil {
+		err = c.conn.WriteMessage(data)
+	}
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case r := <-reply:
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		return r.Result, nil
+	case <-c.done:
+		return nil, ErrClosed
+	}
+}
+
+/* Send a command, decode its result into result (may be nil). */
+func (c *Client) Call(method string, params, result interface{}) error {
+	data, err := c.Send(method, params)
+	if err != nil || result == nil || len(data) == 0 {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+/* Deliver events of method (e.g. "Log.entryAdded") to the returned channel until cancel is
+called or the connection closes. The domain must be enabled separately. */
+func (c *Client) Subscribe(method string) (events <-chan Event, cancel func()) {
+	ch := make(chan Event, eventBuffer)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	c.subs[method] = append(c.subs[method], ch)
+
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		subs := c.subs[method]
+		for i, s := range subs {
+			if s == ch {
+				c.subs[method] = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				return
+			}
+		}
+	}
+}
+
+/* Close the connection, pending commands fail and event channels are closed. */
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+/* Done is closed once the connection is gone. */
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) read() {
+	defer c.shutdown()
+	for {
+		data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		msg := new(incoming)
+		if err = json.Unmarshal(data, msg); err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		if msg.ID != 0 {
+			if reply, ok := c.pending[msg.ID]; ok {
+				delete(c.pending, msg.ID)
+				reply <- msg
+			}
+		} else if msg.SessionID == c.session {
+			for _, ch := range c.subs[msg.Method] {
+				select {
+				case ch <- Event{Method: msg.Method, Params: msg.Params}:
+				default:
+				}
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *Client) shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for method, subs := range c.subs {
+		for _, ch := range subs {
+			close(ch)
+		}
+		delete(c.subs, method)
+	}
+	close(c.done)
+}
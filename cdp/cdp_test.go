@@ -0,0 +1,172 @@
+package cdp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"se/websocket"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* DevTools stub: browser endpoint with one page target. Commands are answered in reverse order
+of arrival, two at a time, so replies must be matched by id. "Test.emit" sends an event of the
+page session before its reply. */
+func stub(t *testing.T) (url string, disconnect chan struct{}) {
+	t.Helper()
+	disconnect = make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Upgrade(w, r)
+		if err != nil {
+			t.Errorf("upgrade: %s", err)
+			return
+		}
+		go func() {
+			<-disconnect
+			c.Close()
+		}()
+
+		var held []string
+		for {
+			data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg struct {
+				ID        int64           `json:"id"`
+				SessionID string          `json:"sessionId"`
+				Method    string          `json:"method"`
+				Params    json.RawMessage `json:"params"`
+			}
+			json.Unmarshal(data, &msg)
+
+			var reply string
+			switch msg.Method {
+			case "Target.getTargets":
+				reply = `{"targetInfos":[{"targetId":"W1","type":"service_worker"},{"targetId":"T1","type":"page"}]}`
+			case "Target.attachToTarget":
+				reply = `{"sessionId":"S1"}`
+			case "Test.fail":
+				c.WriteMessage([]byte(fmt.Sprintf(`{"id":%d,"sessionId":"S1","error":{"code":-32601,"message":"not found"}}`, msg.ID)))
+				continue
+			case "Test.emit":
+				c.WriteMessage([]byte(`{"method":"Log.entryAdded","sessionId":"OTHER","params":{"n":0}}`))
+				c.WriteMessage([]byte(`{"method":"Log.entryAdded","sessionId":"S1","params":{"n":1}}`))
+				reply = `{}`
+			default:
+				if msg.SessionID != "S1" {
+					t.Errorf("%s sent to session %q, want S1", msg.Method, msg.SessionID)
+				}
+				reply = fmt.Sprintf(`{"echo":%s}`, msg.Params)
+				held = append(held, fmt.Sprintf(`{"id":%d,"sessionId":"S1","result":%s}`, msg.ID, reply))
+				if len(held) == 2 {
+					c.WriteMessage([]byte(held[1]))
+					c.WriteMessage([]byte(held[0]))
+					held = nil
+				}
+				continue
+			}
+			c.WriteMessage([]byte(fmt.Sprintf(`{"id":%d,"result":%s}`, msg.ID, reply)))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/devtools/browser/B1", disconnect
+}
+
+func connect(t *testing.T) (*Client, chan struct{}) {
+	t.Helper()
+	url, disconnect := stub(t)
+	c, err := Connect(map[string]interface{}{"se:cdp": url})
+	if err != nil {
+		t.Fatalf("connect: %s", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, disconnect
+}
+
+func TestAttachToPage(t *testing.T) {
+	c, _ := connect(t)
+	if c.session != "S1" {
+		t.Errorf("session %q, want S1", c.session)
+	}
+}
+
+func TestSendMatchesReplies(t *testing.T) {
+	c, _ := connect(t)
+
+	results := make(chan error, 2)
+	for _, n := range []int{1, 2} {
+		go func(n int) {
+			var echo struct {
+				Echo struct{ N int }
+			}
+			err := c.Call("Test.echo", map[string]int{"n": n}, &echo)
+			if err == nil && echo.Echo.N != n {
+				err = fmt.Errorf("command %d got the reply of %d", n, echo.Echo.N)
+			}
+			results <- err
+		}(n)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestErrorReply(t *testing.T) {
+	c, _ := connect(t)
+	_, err := c.Send("Test.fail", nil)
+	if e, ok := err.(*Error); !ok || e.Code != -32601 {
+		t.Errorf("got %v, want a cdp error -32601", err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	c, _ := connect(t)
+	events, cancel := c.Subscribe("Log.entryAdded")
+	defer cancel()
+
+	if _, err := c.Send("Test.emit", nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-events:
+		if string(e.Params) != `{"n":1}` {
+			t.Errorf("got event %s, want the one of the page session", e.Params)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event delivered")
+	}
+}
+
+func TestClose(t *testing.T) {
+	c, _ := connect(t)
+	events, _ := c.Subscribe("Log.entryAdded")
+
+	if err := c.Close(); err != nil {
+		t.Errorf("close: %s", err)
+	}
+	if _, ok := <-events; ok {
+		t.Error("event channel still open after Close")
+	}
+	if _, err := c.Send("Test.echo", nil); err != ErrClosed {
+		t.Errorf("send after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestDisconnected(t *testing.T) {
+	c, disconnect := connect(t)
+	close(disconnect)
+
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after the browser went away")
+	}
+	if _, err := c.Send("Test.echo", nil); err != ErrClosed {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,155 @@
+package cdp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// Network
+
+/* Emulate network conditions, throughput in bytes per second (-1 disables throttling). */
+func (c *Client) EmulateNetworkConditions(offline bool, latency time.Duration, download, upload float64) error {
+	if err := c.Call("Network.enable", nil, nil); err != nil {
+		return err
+	}
+	params := map[string]interface{}{
+		"offline":            offline,
+		"latency":            float64(latency) / float64(time.Millisecond),
+		"downloadThroughput": download,
+		"uploadThroughput":   upload,
+	}
+	return c.Call("Network.emulateNetworkConditions", params, nil)
+}
+
+/* Remove network throttling. */
+func (c *Client) ClearNetworkConditions() error {
+	return c.EmulateNetworkConditions(false, 0, -1, -1)
+}
+
+// Emulation
+
+/* Override the geolocation reported to the page, accuracy in meters. */
+func (c *Client) SetGeolocation(latitude, longitude, accuracy float64) error {
+	params := map[string]float64{"latitude": latitude, "longitude": longitude, "accuracy": accuracy}
+	return c.Call("Emulation.setGeolocationOverride", params, nil)
+}
+
+/* Remove the geolocation override. */
+func (c *Client) ClearGeolocation() error {
+	return c.Call("Emulation.clearGeolocationOverride", nil, nil)
+}
+
+/* Emulate a device screen, scale is the device pixel ratio. */
+func (c *Client) SetDeviceMetrics(width, height int, scale float64, mobile bool) error {
+	params := map[string]interface{}{
+		"width":             width,
+		"height":            height,
+		"deviceScaleFactor": scale,
+		"mobile":            mobile,
+	}
+	return c.Call("Emulation.setDeviceMetricsOverride", params, nil)
+}
+
+/* Remove the device metrics override. */
+func (c *Client) ClearDeviceMetrics() error {
+	return c.Call("Emulation.clearDeviceMetricsOverride", nil, nil)
+}
+
+// Performance
+
+/* Current page metrics (JSHeapUsedSize, Nodes, LayoutCount...). */
+func (c *Client) PerformanceMetrics() (map[string]float64, error) {
+	if err := c.Call("Performance.enable", nil, nil); err != nil {
+		return nil, err
+	}
+
+	var reply struct {
+		Metrics []struct {
+			Name  string  `json:"name"`
+			Value float64 `json:"value"`
+		} `json:"metrics"`
+	}
+	if err := c.Call("Performance.getMetrics", nil, &reply); err != nil {
+		return nil, err
+	}
+
+	metrics := make(map[string]float64, len(reply.Metrics))
+	for _, m := range reply.Metrics {
+		metrics[m.Name] = m.Value
+	}
+	return metrics, nil
+}
+
+// Fetch (request interception)
+
+/* Request paused by Intercept. */
+type PausedRequest struct {
+	RequestID string `json:"requestId"`
+	Request   struct {
+		URL      string            `json:"url"`
+		Method   string            `json:"method"`
+		Headers  map[string]string `json:"headers"`
+		PostData string            `json:"postData"`
+	} `json:"request"`
+	ResourceType string `json:"resourceType"`
+}
+
+/* Decides what happens to a paused request, by calling exactly one of Continue, Fulfill or
+Fail on the client. Returned errors are ignored, the request is continued. */
+type InterceptHandler func(c *Client, r *PausedRequest) error
+
+/* Pause requests matching the URL pattern ("*" and "?" wildcards) and hand them to handler,
+on its own goroutine. Call stop to disable the interception. */
+func (c *Client) Intercept(pattern string, handler InterceptHandler) (stop func() error, err error) {
+	events, cancel := c.Subscribe("Fetch.requestPaused")
+	params := map[string]interface{}{
+		"patterns": []map[string]string{{"urlPattern": pattern}},
+	}
+	if err = c.Call("Fetch.enable", params, nil); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go func() {
+		for e := range events {
+			r := new(PausedRequest)
+			if json.Unmarshal(e.Params, r) != nil {
+				continue
+			}
+			if handler(c, r) != nil {
+				c.Continue(r.RequestID)
+			}
+		}
+	}()
+
+	return func() error {
+		cancel()
+		return c.Call("Fetch.disable", nil, nil)
+	}, nil
+}
+
+/* Let a paused request go to the network unchanged. */
+func (c *Client) Continue(requestID string) error {
+	return c.Call("Fetch.continueRequest", map[string]string{"requestId": requestID}, nil)
+}
+
+/* Answer a paused request with a response built here. */
+func (c *Client) Fulfill(requestID string, status int, headers map[string]string, body []byte) error {
+	list := []map[string]string{}
+	for name, value := range headers {
+		list = append(list, map[string]string{"name": name, "value": value})
+	}
+	params := map[string]interface{}{
+		"requestId":       requestID,
+		"responseCode":    status,
+		"responseHeaders": list,
+		"body":            base64.StdEncoding.EncodeToString(body),
+	}
+	return c.Call("Fetch.fulfillRequest", params, nil)
+}
+
+/* Fail a paused request, reason is a Network.ErrorReason such as "Failed" or "ConnectionRefused". */
+func (c *Client) Fail(requestID, reason string) error {
+	return c.Call("Fetch.failRequest", map[string]string{"requestId": requestID, "errorReason": reason}, nil)
+}
@@ -0,0 +1,78 @@
+package se
+
+import (
+	"fmt"
+	"gtf/drivers/log"
+	"se/selenium"
+	"strings"
+)
+
+/* Console check modes, see CheckConsole. */
+const (
+	ConsoleIgnore = iota
+	ConsoleWarn
+	ConsoleFail
+)
+
+/* Returned by navigations when CheckConsole(ConsoleFail) is set and the page logged errors. */
+type ConsoleError struct {
+	URL     string
+	Entries []selenium.LogEntry
+}
+
+func (e *ConsoleError) Error() string {
+	messages := make([]string, len(e.Entries))
+	for i, entry := range e.Entries {
+		messages[i] = entry.Message
+	}
+	return fmt.Sprintf("%d console error(s) on %s: %s", len(e.Entries), e.URL, strings.Join(messages, "; "))
+}
+
+/* Check the browser console after each navigation: SEVERE entries that appeared since the
+previous check are logged as warnings (ConsoleWarn) or returned as *ConsoleError (ConsoleFail).
+Chromedriver reports browser logs only with the BrowserLogging capability. */
+func CheckConsole(mode int) PageOption {
+	return func(p *Page) {
+		p.consoleCheck = mode
+	}
+}
+
+/* Browser log entries at SEVERE level collected since the previous call. */
+func (p *Page) ConsoleErrors() ([]selenium.LogEntry, error) {
+	entries, err := p.webDriver.Log(selenium.LogBrowser)
+	if err != nil {
+		return nil, err
+	}
+
+	var severe []selenium.LogEntry
+	for _, e := range entries {
+		if e.Level == selenium.LevelSevere {
+			severe = append(severe, e)
+		}
+	}
+	return severe, nil
+}
+
+func (p *Page) checkConsole() error {
+	if p.consoleCheck == ConsoleIgnore {
+		return nil
+	}
+
+	severe, err := p.ConsoleErrors()
+	if err != nil {
+		log.Warningf("Console check disabled, browser log unavailable: %s\n", err)
+		p.consoleCheck = ConsoleIgnore
+		return nil
+	}
+	if len(severe) == 0 {
+		return nil
+	}
+
+	url, _ := p.webDriver.CurrentURL()
+	cerr := &ConsoleError{URL: url, Entries: severe}
+	if p.consoleCheck == ConsoleFail {
+		return cerr
+	}
+	log.Warning(cerr)
+	return nil
+}
@@ -11,6 +11,8 @@ type Element struct {
 	webElement  selenium.WebElement // The Element got form the page e.
 	selector    string              // The element selector used to retrieve the element.
 	selStrategy int                 // The element selector strategy, see: http://code.google.com/p/selenium/wiki/JsonWireProtocol#/session/:sessionId/element
+	secret      bool                // Text typed into the element is left out of logs and traces.
+	preClear    bool                // Set by PreClear, the next SetText clears the element first.
 }
 
 func (e *Element) descendant(selector string) *Element {
@@ -0,0 +1,248 @@
+/* Assertions on elements and pages that retry until they pass or time out:
+
+	expect.Expect(t, p.Div(se.ById, "status")).ToHaveText("Saved")
+	expect.ExpectPage(t, &p.Page).ToHaveURL("/settings/*")
+
+Failures stop the test (t.Fatalf). Soft assertions report with t.Errorf and carry on, so one
+run shows every mismatch:
+
+	soft := expect.Soft(t)
+	soft.Expect(name).ToHaveText("bob")
+	soft.Expect(save).ToBeEnabled()
+*/
+package expect
+
+import (
+	"fmt"
+	"se"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/* Time assertions retry by default, see Asserter.Within. */
+var DefaultTimeout = 5 * time.Second
+
+/* Interval between two tries. */
+var PollInterval = 100 * time.Millisecond
+
+/* Creates expectations for a test. */
+type Asserter struct {
+	t        testing.TB
+	soft     bool
+	timeout  time.Duration
+	failures *failures // Shared with the copies made by Within.
+}
+
+type failures struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+/* Asserter failing the test at the first failed expectation. */
+func New(t testing.TB) *Asserter {
+	return &Asserter{t: t, timeout: DefaultTimeout, failures: new(failures)}
+}
+
+/* Asserter reporting failed expectations with t.Errorf, the test goes on. */
+func Soft(t testing.TB) *Asserter {
+	return &Asserter{t: t, soft: true, timeout: DefaultTimeout, failures: new(failures)}
+}
+
+/* Copy of the asserter retrying for timeout, its failures are those of a. */
+func (a *Asserter) Within(timeout time.Duration) *Asserter {
+	return &Asserter{t: a.t, soft: a.soft, timeout: timeout, failures: a.failures}
+}
+
+/* Messages of the expectations that failed so far, including those made through Within. */
+func (a *Asserter) Failures() []string {
+	a.failures.mu.Lock()
+	defer a.failures.mu.Unlock()
+	return append([]string(nil), a.failures.messages...)
+}
+
+func (a *Asserter) Expect(e *se.Element) *ElementExpectation {
+	return &ElementExpectation{a, e}
+}
+
+func (a *Asserter) ExpectPage(p *se.Page) *PageExpectation {
+	return &PageExpectation{a, p}
+}
+
+/* Shorthand for New(t).Expect(e). */
+func Expect(t testing.TB, e *se.Element) *ElementExpectation {
+	return New(t).Expect(e)
+}
+
+/* Shorthand for New(t).ExpectPage(p). */
+func ExpectPage(t testing.TB, p *se.Page) *PageExpectation {
+	return New(t).ExpectPage(p)
+}
+
+/* Retry check until it passes or the timeout expires. check returns whether it passed and
+the actual value for the failure message. */
+func (a *Asserter) poll(subject, expected string, check func() (bool, string)) bool {
+	a.t.Helper()
+
+	deadline := time.Now().Add(a.timeout)
+	for {
+		ok, actual := check()
+		if ok {
+			return true
+		}
+		if time.Now().After(deadline) {
+			a.fail(fmt.Sprintf("%s: expected %s, got %s after %s", subject, expected, actual, a.timeout))
+			return false
+		}
+		time.Sleep(PollInterval)
+	}
+}
+
+func (a *Asserter) fail(message string) {
+	a.t.Helper()
+
+	a.failures.mu.Lock()
+	a.failures.messages = append(a.failures.messages, message)
+	a.failures.mu.Unlock()
+	if a.soft {
+		a.t.Errorf("%s", message)
+	} else {
+		a.t.Fatalf("%s", message)
+	}
+}
+
+/* Expectations on an element, the element is looked up again on every try. */
+type ElementExpectation struct {
+	a *Asserter
+	e *se.Element
+}
+
+func (x *ElementExpectation) subject() string {
+	if l := x.e.Locator(); l != "" {
+		return l
+	}
+	return "element"
+}
+
+/* Look the element up again and read one of its properties. */
+func (x *ElementExpectation) read(property func() (string, error)) (string, bool) {
+	if err := x.e.Relocate(); err != nil {
+		return fmt.Sprintf("no element (%s)", err), false
+	}
+	v, err := property()
+	if err != nil {
+		return fmt.Sprintf("error %s", err), false
+	}
+	return v, true
+}
+
+/* Text of the element, leading and trailing white space ignored. */
+func (x *ElementExpectation) ToHaveText(text string) bool {
+	x.a.t.Helper()
+	return x.a.poll(x.subject(), fmt.Sprintf("text %q", text), func() (bool, string) {
+		actual, ok := x.read(x.e.Text)
+		if !ok {
+			return false, actual
+		}
+		return strings.TrimSpace(actual) == strings.TrimSpace(text), fmt.Sprintf("%q", actual)
+	})
+}
+
+/* Text of the element contains text. */
+func (x *ElementExpectation) ToContainText(text string) bool {
+	x.a.t.Helper()
+	return x.a.poll(x.subject(), fmt.Sprintf("text containing %q", text), func() (bool, string) {
+		actual, ok := x.read(x.e.Text)
+		if !ok {
+			return false, actual
+		}
+		return strings.Contains(actual, text), fmt.Sprintf("%q", actual)
+	})
+}
+
+func (x *ElementExpectation) ToHaveAttribute(name, value string) bool {
+	x.a.t.Helper()
+	return x.a.poll(x.subject(), fmt.Sprintf("attribute %s=%q", name, value), func() (bool, string) {
+		actual, ok := x.read(func() (string, error) { return x.e.GetAttribute(name) })
+		if !ok {
+			return false, actual
+		}
+		return actual == value, fmt.Sprintf("%s=%q", name, actual)
+	})
+}
+
+func (x *ElementExpectation) ToBeVisible() bool {
+	x.a.t.Helper()
+	return x.a.poll(x.subject(), "visible", x.state(x.e.IsDisplayed, "visible", "hidden"))
+}
+
+func (x *ElementExpectation) ToBeEnabled() bool {
+	x.a.t.Helper()
+	return x.a.poll(x.subject(), "enabled", x.state(x.e.IsEnabled, "enabled", "disabled"))
+}
+
+/* Check of a boolean property, named yes or no in messages. */
+func (x *ElementExpectation) state(property func() (bool, error), yes, no string) func() (bool, string) {
+	return func() (bool, string) {
+		var set bool
+		actual, ok := x.read(func() (string, error) {
+			var err error
+			set, err = property()
+			return "", err
+		})
+		if !ok {
+			return false, actual
+		}
+		if set {
+			return true, yes
+		}
+		return false, no
+	}
+}
+
+/* Number of elements matching the locator. */
+func (x *ElementExpectation) ToHaveCount(n int) bool {
+	x.a.t.Helper()
+	return x.a.poll(x.subject(), fmt.Sprintf("%d element(s)", n), func() (bool, string) {
+		count, err := x.e.Count()
+		if err != nil {
+			return false, fmt.Sprintf("error %s", err)
+		}
+		return count == n, fmt.Sprintf("%d", count)
+	})
+}
+
+/* Expectations on a page. */
+type PageExpectation struct {
+	a *Asserter
+	p *se.Page
+}
+
+/* Current url matches pattern, see se.Page.WaitForURL for the syntax. Patterns without
+wildcards match the end of the url. */
+func (x *PageExpectation) ToHaveURL(pattern string) bool {
+	x.a.t.Helper()
+	return x.a.poll("page", fmt.Sprintf("url %q", pattern), func() (bool, string) {
+		url, err := x.p.URL()
+		if err != nil {
+			return false, fmt.Sprintf("error %s", err)
+		}
+		ok, err := se.MatchURL(pattern, url)
+		if err != nil {
+			return false, fmt.Sprintf("invalid pattern %s", err)
+		}
+		return ok, url
+	})
+}
+
+func (x *PageExpectation) ToHaveTitle(title string) bool {
+	x.a.t.Helper()
+	return x.a.poll("page", fmt.Sprintf("title %q", title), func() (bool, string) {
+		actual, err := x.p.Title()
+		if err != nil {
+			return false, fmt.Sprintf("error %s", err)
+		}
+		return actual == title, fmt.Sprintf("%q", actual)
+	})
+}
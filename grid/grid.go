@@ -0,0 +1,213 @@
+/* Selenium Grid hub client: readiness, node capacity and the node running a session.
+Works with Grid 3 (/grid/api/*) and Grid 4 (/status lists the nodes). */
+package grid
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"se/selenium"
+	"strings"
+	"time"
+)
+
+/* Hub of a grid. */
+type Hub struct {
+	URL    string // Root url of the hub, e.g. http://hub:4444
+	Client *http.Client
+}
+
+/* Hub status, Nodes is only reported by Grid 4. */
+type Status struct {
+	Ready   bool
+	Message string
+	Nodes   []Node
+}
+
+/* Node of the grid. */
+type Node struct {
+	ID           string
+	URI          string
+	Availability string // "UP", "DRAINING" or "DOWN", Grid 4 only.
+	MaxSessions  int
+	Slots        int
+	Sessions     []string // Ids of the sessions running on the node.
+}
+
+/* Number of slots not running a session. */
+func (n *Node) Free() int {
+	return n.Slots - len(n.Sessions)
+}
+
+func (n *Node) String() string {
+	if n.ID == "" {
+		return n.URI
+	}
+	return fmt.Sprintf("%s (%s)", n.URI, n.ID)
+}
+
+/* Slot counts of the whole grid. */
+type Capacity struct {
+	Free  int
+	Total int
+}
+
+/* Hub behind executor, the url passed to selenium.NewRemote. Empty means
+selenium.DEFAULT_EXECUTOR. */
+func NewHub(executor string) *Hub {
+	if executor == "" {
+		executor = selenium.DEFAULT_EXECUTOR
+	}
+	root := strings.TrimSuffix(strings.TrimSuffix(executor, "/"), "/wd/hub")
+	return &Hub{URL: root, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (h *Hub) get(path string, v interface{}) error {
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Get(h.URL + path)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("grid: GET %s: %s", path, res.Status)
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("grid: GET %s: %s", path, err)
+	}
+	return nil
+}
+
+type statusReply struct {
+	Value struct {
+		Ready   bool   `json:"ready"`
+		Message string `json:"message"`
+		Nodes   []struct {
+			ID           string `json:"id"`
+			URI          string `json:"uri"`
+			Availability string `json:"availability"`
+			MaxSessions  int    `json:"maxSessions"`
+			Slots        []struct {
+				Session *struct {
+					SessionID string `json:"sessionId"`
+				} `json:"session"`
+			} `json:"slots"`
+		} `json:"nodes"`
+	} `json:"value"`
+}
+
+/* Hub readiness and, on Grid 4, its nodes. */
+func (h *Hub) Status() (*Status, error) {
+	reply := new(statusReply)
+	if err := h.get("/status", reply); err != nil {
+		/* Grid 3 answers /status under the wire protocol prefix only. */
+		if err2 := h.get("/wd/hub/status", reply); err2 != nil {
+			return nil, err
+		}
+	}
+
+	s := &Status{Ready: reply.Value.Ready, Message: reply.Value.Message}
+	for _, n := range reply.Value.Nodes {
+		node := Node{
+			ID:           n.ID,
+			URI:          n.URI,
+			Availability: n.Availability,
+			MaxSessions:  n.MaxSessions,
+			Slots:        len(n.Slots),
+		}
+		for _, slot := range n.Slots {
+			if slot.Session != nil {
+				node.Sessions = append(node.Sessions, slot.Session.SessionID)
+			}
+		}
+		s.Nodes = append(s.Nodes, node)
+	}
+	return s, nil
+}
+
+/* Registered nodes, Grid 3 hubs don't list them and return none. */
+func (h *Hub) Nodes() ([]Node, error) {
+	s, err := h.Status()
+	if err != nil {
+		return nil, err
+	}
+	return s.Nodes, nil
+}
+
+type hubReply struct {
+	Success    bool `json:"success"`
+	SlotCounts *struct {
+		Free  int `json:"free"`
+		Total int `json:"total"`
+	} `json:"slotCounts"`
+}
+
+/* Free and total slots. Uses /grid/api/hub on Grid 3, the node list on Grid 4. */
+func (h *Hub) Capacity() (Capacity, error) {
+	reply := new(hubReply)
+	if err := h.get("/grid/api/hub", reply); err == nil && reply.SlotCounts != nil {
+		return Capacity{Free: reply.SlotCounts.Free, Total: reply.SlotCounts.Total}, nil
+	}
+
+	nodes, err := h.Nodes()
+	if err != nil {
+		return Capacity{}, err
+	}
+	var c Capacity
+	for _, n := range nodes {
+		c.Total += n.Slots
+		c.Free += n.Free()
+	}
+	return c, nil
+}
+
+type testSessionReply struct {
+	Success bool   `json:"success"`
+	Msg     string `json:"msg"`
+	ProxyID string `json:"proxyId"`
+}
+
+/* Node running session. Asks /grid/api/testsession on Grid 3, searches the slots of the
+nodes on Grid 4. */
+func (h *Hub) SessionNode(session string) (*Node, error) {
+	reply := new(testSessionReply)
+	path := "/grid/api/testsession?session=" + url.QueryEscape(session)
+	if err := h.get(path, reply); err == nil && reply.Success && reply.ProxyID != "" {
+		return &Node{URI: reply.ProxyID, Sessions: []string{session}}, nil
+	}
+
+	nodes, err := h.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		for _, id := range nodes[i].Sessions {
+			if id == session {
+				return &nodes[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("grid: session %s not found on %s", session, h.URL)
+}
+
+/* Look up the node running the session of wd and attach it to wd, it then shows up in the
+driver's failure logs and status errors. This is opt-in: drivers don't know about the grid,
+call Locate once after starting a session on one. */
+func (h *Hub) Locate(wd selenium.WebDriver) (*Node, error) {
+	node, err := h.SessionNode(wd.SessionId())
+	if err != nil {
+		return nil, err
+	}
+	wd.SetNode(node.String())
+	return node, nil
+}
@@ -0,0 +1,135 @@
+package grid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"se/selenium"
+	"strings"
+	"testing"
+)
+
+/* Grid 3 hub: /status only under /wd/hub, slot counts and session lookup under /grid/api. */
+func grid3(t *testing.T) *Hub {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wd/hub/status":
+			w.Write([]byte(`{"status":0,"value":{"ready":true,"message":"Hub has capacity","build":{"version":"3.141.59"}}}`))
+		case "/grid/api/hub":
+			w.Write([]byte(`{"success":true,"slotCounts":{"free":3,"total":5}}`))
+		case "/grid/api/testsession":
+			if r.URL.Query().Get("session") == "abc" {
+				w.Write([]byte(`{"success":true,"msg":"slot found !","proxyId":"http://10.0.0.7:5555"}`))
+				return
+			}
+			w.Write([]byte(`{"success":false,"msg":"Cannot find test slot running session in the registry."}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return NewHub(srv.URL + "/wd/hub")
+}
+
+const grid4Status = `{"value":{"ready":true,"message":"Selenium Grid ready.","nodes":[
+	{"id":"n1","uri":"http://10.0.0.8:5555","availability":"UP","maxSessions":2,
+	 "slots":[{"session":{"sessionId":"abc"}},{"session":null}]},
+	{"id":"n2","uri":"http://10.0.0.9:5555","availability":"DRAINING","maxSessions":1,
+	 "slots":[{"session":null}]}]}}`
+
+/* Grid 4 hub: nodes and their slots in /status, no /grid/api. Session "abc" exists and fails
+every command with "no such element". */
+func grid4(t *testing.T) *Hub {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/status":
+			w.Write([]byte(grid4Status))
+		case r.URL.Path == "/session/abc":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"sessionId":"abc","status":0,"value":{"browserName":"chrome"}}`))
+		case strings.HasPrefix(r.URL.Path, "/session/abc/"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"sessionId":"abc","status":7,"value":{"message":"no such element"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return NewHub(srv.URL)
+}
+
+func TestStatus(t *testing.T) {
+	s, err := grid3(t).Status()
+	if err != nil {
+		t.Fatalf("grid 3: %s", err)
+	}
+	if !s.Ready || s.Message != "Hub has capacity" || len(s.Nodes) != 0 {
+		t.Errorf("grid 3: got %+v", s)
+	}
+
+	s, err = grid4(t).Status()
+	if err != nil {
+		t.Fatalf("grid 4: %s", err)
+	}
+	if !s.Ready || len(s.Nodes) != 2 {
+		t.Fatalf("grid 4: got %+v", s)
+	}
+	n := s.Nodes[0]
+	if n.ID != "n1" || n.Availability != "UP" || n.Slots != 2 || n.Free() != 1 || len(n.Sessions) != 1 || n.Sessions[0] != "abc" {
+		t.Errorf("grid 4: first node %+v", n)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	for name, hub := range map[string]*Hub{"grid 3": grid3(t), "grid 4": grid4(t)} {
+		c, err := hub.Capacity()
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		want := Capacity{Free: 3, Total: 5}
+		if name == "grid 4" {
+			want = Capacity{Free: 2, Total: 3}
+		}
+		if c != want {
+			t.Errorf("%s: got %+v, want %+v", name, c, want)
+		}
+	}
+}
+
+func TestSessionNode(t *testing.T) {
+	for name, hub := range map[string]*Hub{"grid 3": grid3(t), "grid 4": grid4(t)} {
+		n, err := hub.SessionNode("abc")
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		want := "http://10.0.0.7:5555"
+		if name == "grid 4" {
+			want = "http://10.0.0.8:5555 (n1)"
+		}
+		if n.String() != want {
+			t.Errorf("%s: node %q, want %q", name, n, want)
+		}
+
+		if _, err = hub.SessionNode("gone"); err == nil {
+			t.Errorf("%s: found a node for an unknown session", name)
+		}
+	}
+}
+
+func TestLocate(t *testing.T) {
+	hub := grid4(t)
+	wd, err := selenium.Attach(hub.URL, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd.SetDebug(false)
+	if _, err = hub.Locate(wd); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = wd.FindElement("id", "missing")
+	if err == nil || !strings.Contains(err.Error(), `"node":"http://10.0.0.8:5555 (n1)"`) {
+		t.Errorf("error %v does not name the node", err)
+	}
+}
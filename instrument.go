@@ -0,0 +1,183 @@
+package se
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/* Script injected into every document the page navigates to. */
+type initScript struct {
+	name, source string
+}
+
+/* Register a script that is injected after every navigation, a script with the same name is
+replaced. Scripts must be idempotent, they may run more than once in a document. */
+func (p *Page) addInitScript(name, source string) {
+	for i, s := range p.initScripts {
+		if s.name == name {
+			p.initScripts[i].source = source
+			return
+		}
+	}
+	p.initScripts = append(p.initScripts, initScript{name, source})
+}
+
+/* Register a script and inject it into the current document right away. */
+func (p *Page) injectNow(name, source string) error {
+	p.addInitScript(name, source)
+	_, err := p.webDriver.ExecuteScript(source, nil)
+	return err
+}
+
+func (p *Page) injectScripts() error {
+	for _, s := range p.initScripts {
+		if _, err := p.webDriver.ExecuteScript(s.source, nil); err != nil {
+			return fmt.Errorf("injecting %s instrumentation: %s", s.name, err)
+		}
+	}
+	if len(p.initScripts) > 0 {
+		_, err := p.webDriver.ExecuteScript(markInjectedScript, nil)
+		return err
+	}
+	return nil
+}
+
+/* Marks the document as instrumented, a document without the mark was loaded behind the
+page's back (link click, form submit, script navigation). */
+const markInjectedScript = `window.__seInjected = true;`
+
+/* Inject the scripts into a document loaded by a step, in one round trip that does nothing
+if the document is still marked. */
+func (p *Page) reinjectScripts() error {
+	if len(p.initScripts) == 0 {
+		return nil
+	}
+	source := "if (window.__seInjected) { return; }\n"
+	for _, s := range p.initScripts {
+		source += "(function() {" + s.source + "\n})();\n"
+	}
+	source += markInjectedScript
+	_, err := p.webDriver.ExecuteScript(source, nil)
+	return err
+}
+
+/* Hooks console.*, window.onerror and unhandled promise rejections into window.__seConsole.
+Undrained events are parked in sessionStorage when the document unloads and picked up by the
+next document of the same origin. */
+const consoleScript = `
+if (window.__seConsole) { return; }
+var key = '__seConsole', buf = [];
+try { buf = JSON.parse(window.sessionStorage.getItem(key)) || []; window.sessionStorage.removeItem(key); } catch (e) {}
+window.__seConsole = buf;
+var push = function(ev) {
+	ev.timestamp = Date.now();
+	ev.url = window.location.href;
+	if (window.__seConsole.length >= 1000) { window.__seConsole.shift(); }
+	window.__seConsole.push(ev);
+};
+var text = function(args) {
+	var out = [];
+	for (var i = 0; i < args.length; i++) {
+		var a = args[i];
+		if (a instanceof Error) { out.push(a.stack || String(a)); continue; }
+		if (typeof a === 'object') { try { out.push(JSON.stringify(a)); continue; } catch (e) {} }
+		out.push(String(a));
+	}
+	return out.join(' ');
+};
+['log', 'info', 'warn', 'error', 'debug'].forEach(function(level) {
+	var orig = console[level];
+	console[level] = function() {
+		push({type: 'console', level: level, message: text(arguments)});
+		if (orig) { return orig.apply(console, arguments); }
+	};
+});
+window.addEventListener('error', function(e) {
+	push({type: 'error', level: 'error', message: e.message || String(e), source: e.filename || '',
+		line: e.lineno || 0, column: e.colno || 0, stack: e.error && e.error.stack || ''});
+});
+window.addEventListener('unhandledrejection', function(e) {
+	var r = e.reason;
+	push({type: 'rejection', level: 'error', message: r && r.message || String(r), stack: r && r.stack || ''});
+});
+window.addEventListener('pagehide', function() {
+	try { window.sessionStorage.setItem(key, JSON.stringify(window.__seConsole)); } catch (e) {}
+});`
+
+const drainConsoleScript = `
+var b = window.__seConsole || [];
+if (window.__seConsole) { window.__seConsole = []; }
+return b;`
+
+/* Event captured by the console instrumentation. */
+type ConsoleEvent struct {
+	Type      string // "console", "error" (uncaught exception) or "rejection" (unhandled promise rejection).
+	Level     string // Console method (log, info, warn, error, debug), "error" for the other types.
+	Message   string
+	Source    string // Script URL of uncaught exceptions.
+	Line      int
+	Column    int
+	Stack     string
+	URL       string // Document the event occurred in.
+	Timestamp time.Time
+}
+
+type consoleEvent struct {
+	Type      string  `json:"type"`
+	Level     string  `json:"level"`
+	Message   string  `json:"message"`
+	Source    string  `json:"source"`
+	Line      int     `json:"line"`
+	Column    int     `json:"column"`
+	Stack     string  `json:"stack"`
+	URL       string  `json:"url"`
+	Timestamp float64 `json:"timestamp"`
+}
+
+/* Capture console output and JS errors through injected instrumentation, for drivers without
+the log endpoints. See DrainConsole. */
+func CaptureConsole() PageOption {
+	return func(p *Page) {
+		p.addInitScript("console", consoleScript)
+	}
+}
+
+/* Start console capture on an already open page. */
+func (p *Page) CaptureConsole() error {
+	return p.injectNow("console", consoleScript)
+}
+
+/* Return and clear the events captured since the previous call. */
+func (p *Page) DrainConsole() ([]ConsoleEvent, error) {
+	data, err := p.webDriver.ExecuteScriptRaw(drainConsoleScript, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := new(struct{ Value []consoleEvent })
+	if err = json.Unmarshal(data, reply); err != nil {
+		return nil, err
+	}
+
+	events := make([]ConsoleEvent, len(reply.Value))
+	for i, e := range reply.Value {
+		events[i] = ConsoleEvent{
+			Type:      e.Type,
+			Level:     e.Level,
+			Message:   e.Message,
+			Source:    e.Source,
+			Line:      e.Line,
+			Column:    e.Column,
+			Stack:     e.Stack,
+			URL:       e.URL,
+			Timestamp: msTime(e.Timestamp),
+		}
+	}
+	return events, nil
+}
+
+/* Convert a JS timestamp (milliseconds since the epoch). */
+func msTime(ms float64) time.Time {
+	return time.Unix(0, int64(ms*float64(time.Millisecond)))
+}
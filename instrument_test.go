@@ -0,0 +1,28 @@
+package se
+
+import (
+	"strings"
+	"testing"
+)
+
+/* A click may load a new document, it gets the instrumentation like one loaded by Navigate. */
+func TestReinjectAfterClick(t *testing.T) {
+	b, executor := newBrowserStub(t)
+	p := openStubPage(t, executor, "http://app.local/", CaptureConsole())
+	e, err := p.FindElement(ById, "next")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.run = nil
+	if err = e.Click(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.run) != 1 {
+		t.Fatalf("%d scripts run after the click, want 1", len(b.run))
+	}
+	script := b.run[0]
+	if !strings.HasPrefix(script, "if (window.__seInjected) { return; }") || !strings.Contains(script, consoleScript) {
+		t.Errorf("click not followed by a guarded re-injection:\n%s", script)
+	}
+}
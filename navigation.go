@@ -0,0 +1,158 @@
+package se
+
+import (
+	"fmt"
+	"regexp"
+	"se/selenium"
+	"time"
+)
+
+/* Time a navigation may take to reach its load condition, matches the "page load" timeout
+set by OpenPage. */
+const defaultLoadTimeout = 15 * time.Second
+
+/* Condition a navigation waits for before it returns, see WaitUntil. */
+type LoadCondition func(p *Page, timeout time.Duration) error
+
+/* Wait until document.readyState is "complete". */
+func LoadComplete(p *Page, timeout time.Duration) error {
+	return Until(func(p *Page) (bool, error) {
+		state, err := p.webDriver.ExecuteScript("return document.readyState;", nil)
+		return state == "complete", err
+	})(p, timeout)
+}
+
+/* Wait until the document is complete and no XHR/fetch request was pending for quiet. */
+func NetworkIdle(quiet time.Duration) LoadCondition {
+	return func(p *Page, timeout time.Duration) error {
+		start := time.Now()
+		if err := LoadComplete(p, timeout); err != nil {
+			return err
+		}
+		return p.WaitForNetworkIdle(quiet, timeout-time.Since(start))
+	}
+}
+
+/* Wait until predicate returns true. */
+func Until(predicate func(p *Page) (bool, error)) LoadCondition {
+	return func(p *Page, timeout time.Duration) error {
+		deadline := time.Now().Add(timeout)
+		for {
+			ok, err := predicate(p)
+			if err != nil {
+				return err
+			}
+			if ok {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("load condition not reached after %s", timeout)
+			}
+			time.Sleep(pollInterval)
+		}
+	}
+}
+
+/* Load condition every navigation of the page waits for, nil (the default) returns as soon as the
+driver does. */
+func WaitUntil(condition LoadCondition) PageOption {
+	return func(p *Page) {
+		p.loadCondition = condition
+	}
+}
+
+/* Set the load condition of an already open page, see WaitUntil. */
+func (p *Page) SetWaitUntil(condition LoadCondition) {
+	p.loadCondition = condition
+}
+
+func (p *Page) waitLoadCondition() error {
+	if p.loadCondition == nil {
+		return nil
+	}
+	return p.loadCondition(p, defaultLoadTimeout)
+}
+
+/* Open url, relative urls are resolved against the page url. */
+func (p *Page) Navigate(url string) error {
+	abs, err := selenium.NormalizeURL(url, p.url)
+	if err != nil {
+		return err
+	}
+	return p.step("navigate", "", []string{abs}, func() error {
+		if err := p.webDriver.Get(abs); err != nil {
+			return err
+		}
+		return p.afterNavigate()
+	})
+}
+
+/* Move backward in history. */
+func (p *Page) Back() error {
+	return p.step("back", "", nil, func() error {
+		if err := p.webDriver.Back(); err != nil {
+			return err
+		}
+		return p.afterNavigate()
+	})
+}
+
+/* Move forward in history. */
+func (p *Page) Forward() error {
+	return p.step("forward", "", nil, func() error {
+		if err := p.webDriver.Forward(); err != nil {
+			return err
+		}
+		return p.afterNavigate()
+	})
+}
+
+/* Reload the current document. */
+func (p *Page) Refresh() error {
+	return p.step("refresh", "", nil, func() error {
+		if err := p.webDriver.Refresh(); err != nil {
+			return err
+		}
+		return p.afterNavigate()
+	})
+}
+
+/* Current url. */
+func (p *Page) URL() (string, error) {
+	return p.webDriver.CurrentURL()
+}
+
+/* Page title. */
+func (p *Page) Title() (string, error) {
+	return p.webDriver.Title()
+}
+
+/* Source (DOM serialized as HTML) of the current document. */
+func (p *Page) Source() (string, error) {
+	return p.webDriver.PageSource()
+}
+
+/* Check if url matches pattern, see WaitForURL. */
+func MatchURL(pattern, url string) (bool, error) {
+	return regexp.MatchString(routeRegexp(pattern), url)
+}
+
+/* Wait until the current url matches pattern: "*" matches anything but "/", "**" anything,
+patterns starting with "^" are regular expressions. Patterns without a scheme match the end
+of the url (query and fragment ignored), e.g. "/settings/*.html". */
+func (p *Page) WaitForURL(pattern string, timeout time.Duration) error {
+	re, err := regexp.Compile(routeRegexp(pattern))
+	if err != nil {
+		return err
+	}
+
+	var current string
+	err = Until(func(p *Page) (bool, error) {
+		current, err = p.webDriver.CurrentURL()
+		return re.MatchString(current), err
+	})(p, timeout)
+	if err != nil && current != "" {
+		return fmt.Errorf("url %s does not match %q after %s", current, pattern, timeout)
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+package se
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/* Counts pending XHR/fetch requests and logs finished ones into window.__seNet. The log is
+parked in sessionStorage when the document unloads, like the console buffer. */
+const networkScript = `
+if (window.__seNet) { return; }
+var key = '__seNet', net = {pending: 0, last: Date.now(), log: []};
+try { net.log = JSON.parse(window.sessionStorage.getItem(key)) || []; window.sessionStorage.removeItem(key); } catch (e) {}
+window.__seNet = net;
+var begin = function(type, method, url) {
+	net.pending++;
+	net.last = Date.now();
+	return {type: type, method: (method || 'GET').toUpperCase(), url: String(url), start: Date.now(), status: 0, error: ''};
+};
+var end = function(req, status, error) {
+	net.pending = Math.max(0, net.pending - 1);
+	net.last = Date.now();
+	req.status = status || 0;
+	req.error = error || '';
+	req.duration = net.last - req.start;
+	if (net.log.length >= 1000) { net.log.shift(); }
+	net.log.push(req);
+};
+var open = XMLHttpRequest.prototype.open, send = XMLHttpRequest.prototype.send;
+XMLHttpRequest.prototype.open = function(method, url) {
+	this.__seReq = {method: method, url: url};
+	return open.apply(this, arguments);
+};
+XMLHttpRequest.prototype.send = function() {
+	var xhr = this, info = xhr.__seReq || {}, req = begin('xhr', info.method, info.url), done = false;
+	var finish = function(error) {
+		if (done) { return; }
+		done = true;
+		end(req, xhr.status, error);
+	};
+	xhr.addEventListener('load', function() { finish(''); });
+	xhr.addEventListener('error', function() { finish('error'); });
+	xhr.addEventListener('abort', function() { finish('abort'); });
+	xhr.addEventListener('timeout', function() { finish('timeout'); });
+	try {
+		return send.apply(xhr, arguments);
+	} catch (e) {
+		finish(String(e));
+		throw e;
+	}
+};
+if (window.fetch) {
+	var fetch = window.fetch;
+	window.fetch = function(input, init) {
+		var method = init && init.method || input && input.method;
+		var req = begin('fetch', method, input && input.url || input);
+		return fetch.apply(this, arguments).then(function(res) {
+			end(req, res.status, '');
+			return res;
+		}, function(err) {
+			end(req, 0, String(err));
+			throw err;
+		});
+	};
+}
+window.addEventListener('pagehide', function() {
+	try { window.sessionStorage.setItem(key, JSON.stringify(net.log)); } catch (e) {}
+});`
+
+const networkStateScript = `
+var net = window.__seNet;
+return net ? {pending: net.pending, idle: Date.now() - net.last} : null;`
+
+const networkLogScript = `
+return window.__seNet ? window.__seNet.log : [];`
+
+const clearNetworkLogScript = `
+if (window.__seNet) { window.__seNet.log = []; }`
+
+/* Request seen by the network instrumentation. */
+type NetworkRequest struct {
+	Type     string // "xhr" or "fetch".
+	Method   string
+	URL      string
+	Status   int    // HTTP status, 0 if the request failed.
+	Error    string // "error", "abort", "timeout" or the fetch rejection, empty on success.
+	Start    time.Time
+	Duration time.Duration
+}
+
+type networkRequest struct {
+	Type     string  `json:"type"`
+	Method   string  `json:"method"`
+	URL      string  `json:"url"`
+	Status   int     `json:"status"`
+	Error    string  `json:"error"`
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+}
+
+/* Track XHR/fetch requests through injected instrumentation, see WaitForNetworkIdle and
+NetworkLog. Requests started before the instrumentation is injected are not seen. */
+func TrackNetwork() PageOption {
+	return func(p *Page) {
+		p.addInitScript("network", networkScript)
+	}
+}
+
+/* Start tracking network requests on an already open page. */
+func (p *Page) TrackNetwork() error {
+	return p.injectNow("network", networkScript)
+}
+
+/* Wait until no XHR/fetch request has been pending for quiet, fail after timeout. Starts
+tracking if it is not on yet. */
+func (p *Page) WaitForNetworkIdle(quiet, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		v, err := p.webDriver.ExecuteScript(networkStateScript, nil)
+		if err != nil {
+			return err
+		}
+
+		state, ok := v.(map[string]interface{})
+		if !ok {
+			/* Not tracked yet, or a new document without instrumentation. */
+			if err = p.TrackNetwork(); err != nil {
+				return err
+			}
+		} else {
+			pending, _ := state["pending"].(float64)
+			idle, _ := state["idle"].(float64)
+			if pending == 0 && time.Duration(idle)*time.Millisecond >= quiet {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("network not idle after %s: %d request(s) pending", timeout, int(pending))
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("network not idle after %s", timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+/* Requests finished since tracking started or the log was last cleared. */
+func (p *Page) NetworkLog() ([]NetworkRequest, error) {
+	data, err := p.webDriver.ExecuteScriptRaw(networkLogScript, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := new(struct{ Value []networkRequest })
+	if err = json.Unmarshal(data, reply); err != nil {
+		return nil, err
+	}
+
+	requests := make([]NetworkRequest, len(reply.Value))
+	for i, r := range reply.Value {
+		requests[i] = NetworkRequest{
+			Type:     r.Type,
+			Method:   r.Method,
+			URL:      r.URL,
+			Status:   r.Status,
+			Error:    r.Error,
+			Start:    msTime(r.Start),
+			Duration: time.Duration(r.Duration * float64(time.Millisecond)),
+		}
+	}
+	return requests, nil
+}
+
+/* Forget the requests logged so far. */
+func (p *Page) ClearNetworkLog() error {
+	_, err := p.webDriver.ExecuteScript(clearNetworkLogScript, nil)
+	return err
+}
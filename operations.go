@@ -3,54 +3,85 @@ package se
 import (
 	"gtf/drivers/log"
 	"se/selenium"
+	"strconv"
 )
 
 /* Click on element */
 func (e *Element) Click() error {
-	validateElement(e)
-	return e.webElement.Click()
+	return e.page.step("click", e.locator(), nil, func() error {
+		validateElement(e)
+		return e.webElement.Click()
+	})
 }
 
 /* Send keys (type) into element */
 func (e *Element) SendKeys(keys string) error {
-	validateElement(e)
-	return e.webElement.SendKeys(keys)
+	return e.page.step("sendKeys", e.locator(), e.textArg(keys), func() error {
+		validateElement(e)
+		if e.secret {
+			return e.webElement.SendSecretKeys(keys)
+		}
+		return e.webElement.SendKeys(keys)
+	})
 }
 
+/* SetText parameter clearing the element before the text is typed, in the same step. */
 func PreClear(e *Element) {
-	err := e.Clear()
-	if err != nil {
-		panic(err)
-	}
+	e.preClear = true
+}
+
+/* SetText parameter marking the element as holding a secret, the text is redacted from logs
+and traces. */
+func Secret(e *Element) {
+	e.secret = true
 }
 
 /* Set text into element */
 func (e *Element) SetText(text string, params ...func(e *Element)) error {
-	validateElement(e)
-	// call functions like PreClear above
+	// call functions like PreClear above, they only mark the element
 	for _, f := range params {
 		f(e)
 	}
-	return e.webElement.SendKeys(text)
+	clear := e.preClear
+	e.preClear = false
+	return e.page.step("setText", e.locator(), e.textArg(text), func() error {
+		validateElement(e)
+		if clear {
+			if err := e.webElement.Clear(); err != nil {
+				return err
+			}
+		}
+		if e.secret {
+			return e.webElement.SendSecretKeys(text)
+		}
+		return e.webElement.SendKeys(text)
+	})
 }
 
 /* Submit */
 func (e *Element) Submit() error {
-	validateElement(e)
-	return e.webElement.Submit()
+	return e.page.step("submit", e.locator(), nil, func() error {
+		validateElement(e)
+		return e.webElement.Submit()
+	})
 }
 
 /* Clear */
 func (e *Element) Clear() error {
-	validateElement(e)
-	err := e.webElement.Clear()
-	return err
+	return e.page.step("clear", e.locator(), nil, func() error {
+		validateElement(e)
+		err := e.webElement.Clear()
+		return err
+	})
 }
 
 /* Move mouse to relative coordinates */
 func (e *Element) MoveTo(xOffset, yOffset int) error {
-	validateElement(e)
-	return e.webElement.MoveTo(xOffset, yOffset)
+	args := []string{strconv.Itoa(xOffset), strconv.Itoa(yOffset)}
+	return e.page.step("moveTo", e.locator(), args, func() error {
+		validateElement(e)
+		return e.webElement.MoveTo(xOffset, yOffset)
+	})
 }
 
 // Finding
@@ -128,6 +159,12 @@ func (e *Element) CssProperty(name string) (string, error) {
 	return e.webElement.CSSProperty(name)
 }
 
+/* PNG screenshot of element. */
+func (e *Element) Screenshot() ([]byte, error) {
+	validateElement(e)
+	return e.webElement.Screenshot()
+}
+
 /* Check if element exists. */
 func (e *Element) DoesExist() (bool, error) {
 	if e.webElement == nil {
@@ -143,10 +180,48 @@ func (e *Element) DoesExist() (bool, error) {
 	return true, nil
 }
 
+/* Locator of the element, e.g. "css selector=input#name", empty for elements found without
+one. */
+func (e *Element) Locator() string {
+	return e.locator()
+}
+
+/* Find the element again, e.g. after the page re-rendered it. Returns the lookup error instead
+of panicking, elements found without a locator are kept. */
+func (e *Element) Relocate() error {
+	if e.selector == "" {
+		return nil
+	}
+	elem, err := e.page.webDriver.FindElement(elemSelector[e.selStrategy], e.selector)
+	if err != nil {
+		e.webElement = nil
+		return err
+	}
+	e.webElement = elem
+	return nil
+}
+
+/* Number of elements on the page matching the locator. */
+func (e *Element) Count() (int, error) {
+	elems, err := e.page.webDriver.FindElements(elemSelector[e.selStrategy], e.selector)
+	return len(elems), err
+}
+
+/* Called instead of panicking when an element can't be found, e.g. to fail a test with
+t.Fatalf. The lookup still panics if handler returns. */
+func OnLookupError(handler func(locator string, err error)) PageOption {
+	return func(p *Page) {
+		p.lookupError = handler
+	}
+}
+
 func validateElement(e *Element) {
 	if e.webElement == nil {
 		elem, err := e.page.webDriver.FindElement(elemSelector[e.selStrategy], e.selector)
 		if err != nil {
+			if e.page.lookupError != nil {
+				e.page.lookupError(e.locator(), err)
+			}
 			log.DoPanic(err)
 		}
 		e.webElement = elem
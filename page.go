@@ -4,21 +4,40 @@ package se
 
 import (
 	"gtf/drivers/log"
+	"image"
 	"se/selenium"
 	"strings"
+	"sync"
 )
 
 /* Page interface implementation */
 type Page struct {
-	webDriver selenium.WebDriver
-	url       string
+	webDriver     selenium.WebDriver
+	url           string
+	consoleCheck  int           // One of ConsoleIgnore, ConsoleWarn and ConsoleFail.
+	initScripts   []initScript  // Instrumentation injected after every navigation.
+	routes        []route       // Mocked XHR/fetch routes, see Route.
+	loadCondition LoadCondition // Waited for after every navigation, see WaitUntil.
+	lease         *lease        // Session, shared by the copies of the page.
+	keepAlive     bool          // Finish leaves the browser open after a failure.
+	observers     []StepObserver
+	lookupError   func(locator string, err error) // See OnLookupError.
 }
 
+/* Page option, see OpenPageWith. */
+type PageOption func(p *Page)
+
 /* Here, the returned type is struct{ Page }, seems tricky, it is used to compatible to the
 customed package, and maky it easy to write costomed package.
 Due to some package may has "type GwLoginPage struct{ Page }" definition to promote methods of webgui
 */
 func OpenPage(url string, wd selenium.WebDriver, params ...func(caps map[string]interface{})) (s struct{ Page }, err error) {
+	return OpenPageWith(url, wd, nil, params...)
+}
+
+/* OpenPage with page options, e.g. OpenPageWith(url, nil, []PageOption{CaptureConsole()}).
+The capability functions are used only when wd is nil. */
+func OpenPageWith(url string, wd selenium.WebDriver, options []PageOption, params ...func(caps map[string]interface{})) (s struct{ Page }, err error) {
 	if wd == nil {
 		caps := selenium.Capabilities{"browserName": "chrome", "takesScreenshot": true} //{android|chrome|firefox|htmlunit|internet explorer|iPhone|iPad|opera|safari}.
 		for _, f := range params {
@@ -32,22 +51,53 @@ func OpenPage(url string, wd selenium.WebDriver, params ...func(caps map[string]
 		wd.MaximizeWindow("current")
 	}
 
-	p := Page{webDriver: wd, url: url}
+	p := Page{webDriver: wd, url: url, lease: &lease{wd: wd}}
+	for _, f := range options {
+		f(&p)
+	}
 	// p.webDriver.SetImplicitWaitTimeout(100)
 	// p.webDriver.SetAsyncScriptTimeout(1000)
 	// p.webDriver.SetTimeout("script", 100)
 	// p.webDriver.SetTimeout("implicit", 100)
 	p.webDriver.SetTimeout("page load", 15000)
 	err = p.Open()
+	if err != nil && p.keepAlive {
+		p.logKeepAlive()
+	}
 	return struct{ Page }{p}, err
 }
 
+/* Leave the browser open when the test fails, Finish(true) prints the session id instead of
+quitting, re-attach with selenium.Attach. */
+func KeepAliveOnFailure() PageOption {
+	return func(p *Page) {
+		p.keepAlive = true
+	}
+}
+
 func Browsername(name string) func(caps map[string]interface{}) {
 	return func(caps map[string]interface{}) {
 		caps["browserName"] = name
 	}
 }
 
+/* Collect browser logs at level and above, needed by CheckConsole on chromedriver. */
+func BrowserLogging(level string) func(caps map[string]interface{}) {
+	return func(caps map[string]interface{}) {
+		prefs := map[string]string{selenium.LogBrowser: level}
+		caps["loggingPrefs"] = prefs
+		caps["goog:loggingPrefs"] = prefs
+	}
+}
+
+/* Ask for a WebDriver BiDi endpoint, see selenium.WebDriver.BiDi. BiDi needs a W3C session,
+element commands that differ from JSON wire (element ids, typing, location) may not work. */
+func EnableBiDi() func(caps map[string]interface{}) {
+	return func(caps map[string]interface{}) {
+		caps["webSocketUrl"] = true
+	}
+}
+
 func Screenshot(b bool) func(caps map[string]interface{}) {
 	return func(caps map[string]interface{}) {
 		caps["browserName"] = b
@@ -70,7 +120,28 @@ func Screenshot(b bool) func(caps map[string]interface{}) {
 
 /* Open page against url. */
 func (p *Page) Open() error {
-	return p.webDriver.Get(p.url)
+	return p.step("open", "", []string{p.url}, func() error {
+		if err := p.webDriver.Get(p.url); err != nil {
+			return err
+		}
+		return p.afterNavigate()
+	})
+}
+
+/* Waits and checks run after every navigation. */
+func (p *Page) afterNavigate() error {
+	if err := p.injectScripts(); err != nil {
+		return err
+	}
+	if err := p.waitLoadCondition(); err != nil {
+		return err
+	}
+	return p.checkConsole()
+}
+
+/* Driver of the page. */
+func (p *Page) WebDriver() selenium.WebDriver {
+	return p.webDriver
 }
 
 /* Close current window. */
@@ -78,9 +149,57 @@ func (p *Page) Close() error {
 	return p.webDriver.Close()
 }
 
-/* Quit (end) current session */
+/* Session of a page, shared by the copies of the page so that it is quit, or given back to its
+pool, once. */
+type lease struct {
+	pool *SessionPool // nil if the page owns the session.
+	wd   selenium.WebDriver
+	once sync.Once
+}
+
+func (l *lease) release() (err error) {
+	l.once.Do(func() {
+		if l.pool == nil {
+			err = l.wd.Quit()
+			return
+		}
+		l.pool.Release(l.wd)
+	})
+	return err
+}
+
+func (l *lease) detach() {
+	l.once.Do(func() {
+		if l.pool != nil {
+			l.pool.detach(l.wd)
+		}
+	})
+}
+
+/* Quit (end) current session, pooled sessions are released to their pool instead. */
 func (p *Page) Quit() error {
-	return p.webDriver.Quit()
+	if p.lease == nil {
+		return p.webDriver.Quit()
+	}
+	return p.lease.release()
+}
+
+/* End the test: quit (or release) the session, unless it failed and the page was opened
+with KeepAliveOnFailure. Usually deferred, e.g. defer func() { p.Finish(t.Failed()) }(). */
+func (p *Page) Finish(failed bool) error {
+	if !failed || !p.keepAlive {
+		return p.Quit()
+	}
+	if p.lease != nil {
+		p.lease.detach()
+	}
+	p.logKeepAlive()
+	return nil
+}
+
+func (p *Page) logKeepAlive() {
+	log.Warningf("Keeping browser session %s open, attach with selenium.Attach(executor, %q)\n",
+		p.webDriver.SessionId(), p.webDriver.SessionId())
 }
 
 func (p *Page) FindElement(by int, selector string) (*Element, error) {
@@ -96,14 +215,18 @@ func (p *Page) FindElement(by int, selector string) (*Element, error) {
 }
 
 func (p *Page) FindElementAndClick(by int, selector string) *Element {
-	elem, err := p.webDriver.FindElement(elemSelector[by], selector)
-	if err != nil {
-		log.Warning(">>>>>>>>>>>>>>>>>>>>>>>>>>>")
-		log.Warning(err)
-		log.Warning("<<<<<<<<<<<<<<<<<<<<<<<<<<<")
-	}
-	elem.Click()
-	return &Element{page: p, webElement: elem}
+	e := &Element{page: p, selStrategy: by, selector: selector}
+	p.step("click", e.locator(), nil, func() error {
+		elem, err := p.webDriver.FindElement(elemSelector[by], selector)
+		if err != nil {
+			log.Warning(">>>>>>>>>>>>>>>>>>>>>>>>>>>")
+			log.Warning(err)
+			log.Warning("<<<<<<<<<<<<<<<<<<<<<<<<<<<")
+		}
+		e.webElement = elem
+		return elem.Click()
+	})
+	return e
 }
 
 func (p *Page) Element(tag string, by int, selector, auxSelector string) *Element {
@@ -160,7 +283,9 @@ func (p *Page) TextBox(by int, selector string) *Element {
 }
 
 func (p *Page) PasswordBox(by int, selector string) *Element {
-	return p.Element("input", by, selector, "[type=password]")
+	e := p.Element("input", by, selector, "[type=password]")
+	e.secret = true
+	return e
 }
 
 func (p *Page) CheckBox(by int, selector string) *Element {
@@ -171,6 +296,27 @@ func (p *Page) Table(by int, selector string) *Element {
 	return p.Element("table", by, selector, "")
 }
 
+/* Take a screenshot, return the name of the file stored by the driver's screenshot sink. */
 func (p *Page) ScreenShot() (string, error) {
 	return p.webDriver.Screenshot()
 }
+
+/* Take a screenshot, return the PNG data without storing a file. */
+func (p *Page) ScreenshotPNG() ([]byte, error) {
+	return p.webDriver.ScreenshotPNG()
+}
+
+/* Take a screenshot, return the decoded image without storing a file. */
+func (p *Page) ScreenshotImage() (image.Image, error) {
+	return p.webDriver.ScreenshotImage()
+}
+
+/* Screenshot of the whole page, scrolled and stitched if the browser can't do it natively. */
+func (p *Page) FullScreenshotPNG() ([]byte, error) {
+	return p.webDriver.FullScreenshotPNG()
+}
+
+/* Screenshot of the whole page as image, see FullScreenshotPNG. */
+func (p *Page) FullScreenshotImage() (image.Image, error) {
+	return p.webDriver.FullScreenshotImage()
+}
@@ -0,0 +1,182 @@
+package se
+
+import (
+	"errors"
+	"fmt"
+	"gtf/drivers/log"
+	"se/selenium"
+	"sync"
+	"time"
+)
+
+/* Returned by Acquire once the pool is closed. */
+var ErrPoolClosed = errors.New("session pool closed")
+
+/* Bounded pool of browser sessions for tests running in parallel. A session is used by one
+goroutine at a time: Acquire hands it out, Release resets it and puts it back. Sessions that
+fail the health check are quit and replaced. */
+type SessionPool struct {
+	caps     selenium.Capabilities
+	executor string
+	slots    chan struct{} // One token per session in use.
+
+	mu     sync.Mutex
+	idle   []selenium.WebDriver
+	out    map[selenium.WebDriver]bool // Sessions handed out and not yet given back.
+	closed bool
+}
+
+/* Pool of at most size sessions started with caps on executor (empty means
+selenium.DEFAULT_EXECUTOR). Sessions are started on demand. */
+func NewSessionPool(size int, caps selenium.Capabilities, executor string) *SessionPool {
+	if size < 1 {
+		size = 1
+	}
+	return &SessionPool{caps: caps, executor: executor, slots: make(chan struct{}, size),
+		out: map[selenium.WebDriver]bool{}}
+}
+
+/* Get a healthy session, waiting up to timeout for one to be released. */
+func (sp *SessionPool) Acquire(timeout time.Duration) (selenium.WebDriver, error) {
+	select {
+	case sp.slots <- struct{}{}:
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("no session available after %s (pool size %d)", timeout, cap(sp.slots))
+	}
+
+	for {
+		sp.mu.Lock()
+		if sp.closed {
+			sp.mu.Unlock()
+			<-sp.slots
+			return nil, ErrPoolClosed
+		}
+		var wd selenium.WebDriver
+		if n := len(sp.idle); n > 0 {
+			wd, sp.idle = sp.idle[n-1], sp.idle[:n-1]
+		}
+		sp.mu.Unlock()
+
+		if wd == nil {
+			break
+		}
+		if err := healthCheck(wd); err != nil {
+			log.Warningf("Replacing session %s: %s\n", wd.SessionId(), err)
+			wd.Quit()
+			continue
+		}
+		return sp.checkOut(wd), nil
+	}
+
+	wd, err := selenium.NewRemote(sp.copyCaps(), sp.executor)
+	if err != nil {
+		<-sp.slots
+		return nil, err
+	}
+	return sp.checkOut(wd), nil
+}
+
+func (sp *SessionPool) checkOut(wd selenium.WebDriver) selenium.WebDriver {
+	sp.mu.Lock()
+	sp.out[wd] = true
+	sp.mu.Unlock()
+	return wd
+}
+
+/* Take wd back from the caller, false if it was not handed out (or was given back already). */
+func (sp *SessionPool) checkIn(wd selenium.WebDriver) bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if !sp.out[wd] {
+		return false
+	}
+	delete(sp.out, wd)
+	<-sp.slots
+	return true
+}
+
+/* Reset a session acquired from the pool and make it available again. Sessions that can't be
+reset are quit, the pool starts a new one when needed. Sessions that are not checked out of the
+pool, e.g. released twice, are ignored. */
+func (sp *SessionPool) Release(wd selenium.WebDriver) {
+	if !sp.checkIn(wd) {
+		log.Warningf("Ignoring release of session %s, it is not checked out of the pool\n", wd.SessionId())
+		return
+	}
+
+	if err := resetSession(wd); err != nil {
+		log.Warningf("Dropping session %s: %s\n", wd.SessionId(), err)
+		wd.Quit()
+		return
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.closed {
+		wd.Quit()
+		return
+	}
+	sp.idle = append(sp.idle, wd)
+}
+
+/* Give up a session without quitting it, e.g. one kept open for inspection. */
+func (sp *SessionPool) detach(wd selenium.WebDriver) {
+	sp.checkIn(wd)
+}
+
+/* Quit the idle sessions, sessions in use are quit when released. */
+func (sp *SessionPool) Close() error {
+	sp.mu.Lock()
+	idle := sp.idle
+	sp.idle, sp.closed = nil, true
+	sp.mu.Unlock()
+
+	var err error
+	for _, wd := range idle {
+		if e := wd.Quit(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+/* Open a page on a pooled session, Page.Quit releases the session back to the pool. */
+func (sp *SessionPool) OpenPage(url string, timeout time.Duration, options ...PageOption) (struct{ Page }, error) {
+	wd, err := sp.Acquire(timeout)
+	if err != nil {
+		return struct{ Page }{}, err
+	}
+	s, err := OpenPageWith(url, wd, options)
+	s.Page.lease = &lease{pool: sp, wd: wd}
+	return s, err
+}
+
+/* Each session gets its own copy, drivers may add to the capabilities they are given. */
+func (sp *SessionPool) copyCaps() selenium.Capabilities {
+	caps := selenium.Capabilities{}
+	for k, v := range sp.caps {
+		caps[k] = v
+	}
+	return caps
+}
+
+/* Check that both the server and the session still respond. */
+func healthCheck(wd selenium.WebDriver) error {
+	if _, err := wd.Status(); err != nil {
+		return err
+	}
+	_, err := wd.CurrentURL()
+	return err
+}
+
+/* Forget the state of the previous test: cookies and web storage of the current document,
+then leave it. */
+func resetSession(wd selenium.WebDriver) error {
+	if err := wd.DeleteAllCookies(); err != nil {
+		return err
+	}
+	/* Documents without storage (about:blank, data: urls) reject these, that is fine. */
+	wd.LocalStorage().Clear()
+	wd.SessionStorage().Clear()
+	return wd.Get("about:blank")
+}
@@ -0,0 +1,80 @@
+package se
+
+import (
+	"se/selenium"
+	"testing"
+	"time"
+)
+
+/* Releasing a session twice frees its slot once and pools it once. */
+func TestPoolReleaseTwice(t *testing.T) {
+	_, executor := newBrowserStub(t)
+	sp := NewSessionPool(1, selenium.Capabilities{"browserName": "stub"}, executor)
+	defer sp.Close()
+
+	wd, err := sp.Acquire(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd.SetDebug(false)
+	sp.Release(wd)
+	sp.Release(wd)
+	if len(sp.idle) != 1 {
+		t.Errorf("%d idle sessions, want 1", len(sp.idle))
+	}
+
+	again, err := sp.Acquire(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != wd {
+		t.Error("released session not reused")
+	}
+	if _, err = sp.Acquire(10 * time.Millisecond); err == nil {
+		t.Error("pool of one handed out two sessions")
+	}
+	sp.Release(again)
+}
+
+/* Copies of a pooled page share the session: the first Quit releases it, later ones are no-ops. */
+func TestPoolPageCopiesReleaseOnce(t *testing.T) {
+	_, executor := newBrowserStub(t)
+	sp := NewSessionPool(1, selenium.Capabilities{"browserName": "stub"}, executor)
+	defer sp.Close()
+
+	s, err := sp.OpenPage("http://app.local/", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.WebDriver().SetDebug(false)
+	page := &s.Page
+	s.Quit()
+	page.Finish(false)
+	s.Quit()
+
+	next, err := sp.OpenPage("http://app.local/", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sp.Acquire(10 * time.Millisecond); err == nil {
+		t.Error("session released more than once")
+	}
+	next.Quit()
+}
+
+/* Copies of a page that owns its session quit it once, e.g. a test quitting the page returned by
+setest.NewPage before the cleanup does. */
+func TestPageCopiesQuitOnce(t *testing.T) {
+	b, executor := newBrowserStub(t)
+	s := *openStubPage(t, executor, "http://app.local/")
+	page := &s
+	if err := s.Quit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := page.Finish(false); err != nil {
+		t.Errorf("finish after quit: %s", err)
+	}
+	if b.quits != 1 {
+		t.Errorf("session quit %d times, want 1", b.quits)
+	}
+}
@@ -0,0 +1,424 @@
+/* Recording HTTP(S) forward proxy. Browsers are pointed at it through the "proxy" capability,
+every request and response passing through is recorded as HAR 1.2. HTTPS is intercepted with
+certificates signed by a CA generated at start-up, so the session must accept untrusted
+certificates (Caps sets acceptInsecureCerts) or trust CACertificate. */
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Headers of a single connection, never forwarded. */
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+type Proxy struct {
+	listener  net.Listener
+	server    *http.Server
+	transport *http.Transport
+	ca        *x509.Certificate
+	caKey     *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	har     *HAR
+	page    string      // Id of the current HAR page, empty outside Start/Stop.
+	pages   int         // Number of pages started so far.
+	headers http.Header // Injected into every forwarded request.
+	blocked []*regexp.Regexp
+	certs   map[string]*tls.Certificate
+}
+
+/* Start a proxy listening on addr, "127.0.0.1:0" if empty. */
+func Listen(addr string) (*Proxy, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+
+	p := &Proxy{
+		har:     newHAR(),
+		headers: http.Header{},
+		certs:   map[string]*tls.Certificate{},
+		transport: &http.Transport{
+			/* Device GUIs usually have self-signed certificates. */
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	if err := p.newCA(); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p.listener = l
+	p.server = &http.Server{Handler: p}
+	go p.server.Serve(l)
+	return p, nil
+}
+
+/* Address the proxy listens on, host:port. */
+func (p *Proxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+/* Stop the proxy. */
+func (p *Proxy) Close() error {
+	p.transport.CloseIdleConnections()
+	return p.server.Close()
+}
+
+/* The "proxy" capability object pointing a browser at this proxy. noProxy is left out, it is
+a string in JSON wire but a list in W3C. */
+func (p *Proxy) Capability() map[string]interface{} {
+	return map[string]interface{}{
+		"proxyType": "manual",
+		"httpProxy": p.Addr(),
+		"sslProxy":  p.Addr(),
+	}
+}
+
+/* Capability function for se.OpenPage, e.g. se.OpenPage(url, nil, p.Caps()). */
+func (p *Proxy) Caps() func(caps map[string]interface{}) {
+	return func(caps map[string]interface{}) {
+		caps["proxy"] = p.Capability()
+		caps["acceptInsecureCerts"] = true
+		caps["acceptSslCerts"] = true
+	}
+}
+
+/* PEM encoded CA certificate the intercepted HTTPS traffic is signed with. */
+func (p *Proxy) CACertificate() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.ca.Raw})
+}
+
+/* Add a header to every forwarded request, replacing the one sent by the browser. */
+func (p *Proxy) InjectHeader(name, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.headers.Set(name, value)
+}
+
+/* Answer requests whose URL matches the regular expression with 403, without forwarding them.
+Blocked requests are recorded with the comment "blocked". */
+func (p *Proxy) Block(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.blocked = append(p.blocked, re)
+	return nil
+}
+
+/* Start a recording marker (e.g. the test name): entries recorded until Stop belong to a new
+HAR page titled name. */
+func (p *Proxy) Start(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pages++
+	p.page = fmt.Sprintf("page_%d", p.pages)
+	p.har.Log.Pages = append(p.har.Log.Pages, Page{StartedDateTime: time.Now(), ID: p.page, Title: name})
+}
+
+/* End the current recording, return the HAR of its page. */
+func (p *Proxy) Stop() *HAR {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := newHAR()
+	for _, page := range p.har.Log.Pages {
+		if page.ID == p.page {
+			h.Log.Pages = append(h.Log.Pages, page)
+		}
+	}
+	for _, e := range p.har.Log.Entries {
+		if e.Pageref == p.page && p.page != "" {
+			h.Log.Entries = append(h.Log.Entries, e)
+		}
+	}
+	p.page = ""
+	return h
+}
+
+/* Everything recorded so far. */
+func (p *Proxy) HAR() *HAR {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := newHAR()
+	h.Log.Pages = append(h.Log.Pages, p.har.Log.Pages...)
+	h.Log.Entries = append(h.Log.Entries, p.har.Log.Entries...)
+	return h
+}
+
+/* Drop everything recorded so far. */
+func (p *Proxy) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.har, p.page = newHAR(), ""
+}
+
+/* Write the HAR as JSON file. */
+func (h *HAR) WriteFile(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.intercept(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "se/proxy: not a proxy request", http.StatusBadRequest)
+		return
+	}
+
+	res, body := p.roundTrip(r)
+	defer res.Body.Close()
+	for name, values := range res.Header {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	w.WriteHeader(res.StatusCode)
+	w.Write(body)
+}
+
+/* Terminate TLS of a CONNECT tunnel and proxy the requests sent through it. */
+func (p *Proxy) intercept(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "se/proxy: hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+	target, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		target = r.Host
+	}
+	tlsConn := tls.Server(conn, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			/* IP addresses are not sent as SNI. */
+			if hello.ServerName != "" {
+				return p.certificate(hello.ServerName)
+			}
+			return p.certificate(target)
+		},
+	})
+	defer tlsConn.Close()
+
+	reader := bufio.NewReader(tlsConn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		req.URL.Scheme, req.URL.Host = "https", r.Host
+
+		res, _ := p.roundTrip(req)
+		err = res.Write(tlsConn)
+		res.Body.Close()
+		if err != nil || req.Close || res.Close {
+			return
+		}
+	}
+}
+
+/* Forward a request and record it. The returned response has its body buffered and no hop
+headers, it is safe to write back as is. */
+func (p *Proxy) roundTrip(r *http.Request) (*http.Response, []byte) {
+	start := time.Now()
+	reqBody, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+
+	entry := &Entry{
+		StartedDateTime: start,
+		Request: Request{
+			Method:      r.Method,
+			URL:         r.URL.String(),
+			HTTPVersion: r.Proto,
+			Cookies:     requestCookies(r),
+			Headers:     headers(r.Header),
+			QueryString: queryString(r.URL),
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+	}
+	if len(reqBody) > 0 {
+		entry.Request.PostData = &PostData{MimeType: r.Header.Get("Content-Type"), Text: string(reqBody)}
+	}
+
+	res, err := p.forward(r, reqBody, entry)
+	if err != nil {
+		res = &http.Response{
+			StatusCode: http.StatusBadGateway,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       ioutil.NopCloser(strings.NewReader(err.Error())),
+		}
+		entry.Comment = err.Error()
+	}
+	sent := time.Now()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	for _, h := range hopHeaders {
+		res.Header.Del(h)
+	}
+	res.Header.Del("Content-Length")
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	res.ContentLength, res.TransferEncoding = int64(len(body)), nil
+	res.Proto, res.ProtoMajor, res.ProtoMinor = "HTTP/1.1", 1, 1
+	if res.Status == "" {
+		res.Status = fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+
+	entry.Response = Response{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: res.Proto,
+		Cookies:     responseCookies(res),
+		Headers:     headers(res.Header),
+		Content:     content(body, res.Header.Get("Content-Type")),
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	entry.Timings = Timings{Wait: milliseconds(sent.Sub(start)), Receive: milliseconds(time.Since(sent))}
+	entry.Time = milliseconds(time.Since(start))
+
+	p.mu.Lock()
+	entry.Pageref = p.page
+	p.har.Log.Entries = append(p.har.Log.Entries, entry)
+	p.mu.Unlock()
+	return res, body
+}
+
+func (p *Proxy) forward(r *http.Request, body []byte, entry *Entry) (*http.Response, error) {
+	p.mu.Lock()
+	injected := p.headers.Clone()
+	blocked := false
+	for _, re := range p.blocked {
+		blocked = blocked || re.MatchString(r.URL.String())
+	}
+	p.mu.Unlock()
+
+	if blocked {
+		entry.Comment = "blocked"
+		return &http.Response{
+			StatusCode: http.StatusForbidden,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       ioutil.NopCloser(strings.NewReader("blocked by se/proxy")),
+		}, nil
+	}
+
+	out, err := http.NewRequest(r.Method, r.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	out.Header = r.Header.Clone()
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	/* Let the transport negotiate compression, it decodes the body for the recording. */
+	out.Header.Del("Accept-Encoding")
+	for name, values := range injected {
+		out.Header[name] = values
+	}
+	out.Host = r.Host
+	return p.transport.RoundTrip(out)
+}
+
+func (p *Proxy) newCA() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "se/proxy CA", Organization: []string{"se"}},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	p.ca, err = x509.ParseCertificate(der)
+	p.caKey = key
+	return err
+}
+
+/* Certificate for host, signed by the proxy CA. */
+func (p *Proxy) certificate(host string) (*tls.Certificate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cert, ok := p.certs[host]; ok {
+		return cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-24 * time.Hour),
+		NotAfter:     time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{Certificate: [][]byte{der, p.ca.Raw}, PrivateKey: key}
+	p.certs[host] = cert
+	return cert, nil
+}
@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+/* Backend answering with the X-Test header it received and a cookie, /logo.png with binary
+content. */
+func backend(hits *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.URL.Path == "/logo.png" {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0, 0xff})
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "42", Path: "/", HttpOnly: true})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"header":          r.Header.Get("X-Test"),
+			"proxyConnection": r.Header.Get("Proxy-Connection"),
+		})
+	}
+}
+
+/* Proxy and a client sending everything through it, trusting the proxy CA. */
+func listen(t *testing.T) (*Proxy, *http.Client) {
+	t.Helper()
+	p, err := Listen("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(p.CACertificate())
+	proxyURL, _ := url.Parse("http://" + p.Addr())
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}}
+	return p, client
+}
+
+func get(t *testing.T, client *http.Client, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	return res, string(body)
+}
+
+func TestRecordHAR(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(backend(&hits))
+	defer srv.Close()
+	p, client := listen(t)
+
+	client.Get(srv.URL + "/before")
+	p.Start("TestLogin")
+	req, _ := http.NewRequest("POST", srv.URL+"/login?next=%2Fhome", strings.NewReader("user=bob"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "lang", Value: "en"})
+	if res, _ := get(t, client, req); res.StatusCode != 200 {
+		t.Fatalf("status %d", res.StatusCode)
+	}
+	req, _ = http.NewRequest("GET", srv.URL+"/logo.png", nil)
+	get(t, client, req)
+	har := p.Stop()
+
+	if len(har.Log.Pages) != 1 || har.Log.Pages[0].Title != "TestLogin" {
+		t.Fatalf("pages %+v", har.Log.Pages)
+	}
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("%d entries in the page, want 2", len(har.Log.Entries))
+	}
+	login, logo := har.Log.Entries[0], har.Log.Entries[1]
+	if login.Pageref != har.Log.Pages[0].ID || login.Request.Method != "POST" || login.Request.URL != srv.URL+"/login?next=%2Fhome" {
+		t.Errorf("login request %+v", login.Request)
+	}
+	if q := login.Request.QueryString; len(q) != 1 || q[0] != (NameValue{"next", "/home"}) {
+		t.Errorf("query string %+v", q)
+	}
+	if c := login.Request.Cookies; len(c) != 1 || c[0].Name != "lang" {
+		t.Errorf("request cookies %+v", c)
+	}
+	if d := login.Request.PostData; d == nil || d.Text != "user=bob" || login.Request.BodySize != 8 {
+		t.Errorf("post data %+v", d)
+	}
+	if r := login.Response; r.Status != 200 || r.Content.MimeType != "application/json" || !strings.Contains(r.Content.Text, `"header"`) {
+		t.Errorf("login response %+v", r)
+	}
+	if c := login.Response.Cookies; len(c) != 1 || c[0].Name != "sid" || !c[0].HTTPOnly {
+		t.Errorf("response cookies %+v", c)
+	}
+	if c := logo.Response.Content; c.Encoding != "base64" || c.Text != "iVBORwD/" || c.Size != 6 {
+		t.Errorf("binary content %+v", c)
+	}
+
+	all := p.HAR()
+	if len(all.Log.Entries) != 3 || all.Log.Entries[0].Pageref != "" {
+		t.Errorf("%d entries in total, want 3 with the first outside any page", len(all.Log.Entries))
+	}
+	path := filepath.Join(t.TempDir(), "login.har")
+	if err := har.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	var decoded HAR
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Log.Version != "1.2" || len(decoded.Log.Entries) != 2 {
+		t.Errorf("written HAR: %v %+v", err, decoded.Log)
+	}
+
+	p.Reset()
+	if n := len(p.HAR().Log.Entries); n != 0 {
+		t.Errorf("%d entries after Reset", n)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(backend(&hits))
+	defer srv.Close()
+	p, client := listen(t)
+
+	if err := p.Block(`/ads/|\.tracker\.`); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Block(`(`); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+	req, _ := http.NewRequest("GET", srv.URL+"/ads/banner.js", nil)
+	res, body := get(t, client, req)
+	if res.StatusCode != http.StatusForbidden || body != "blocked by se/proxy" {
+		t.Errorf("blocked request: %d %q", res.StatusCode, body)
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Error("blocked request reached the server")
+	}
+	req, _ = http.NewRequest("GET", srv.URL+"/app.js", nil)
+	if res, _ = get(t, client, req); res.StatusCode != 200 {
+		t.Errorf("other request: status %d", res.StatusCode)
+	}
+
+	entries := p.HAR().Log.Entries
+	if len(entries) != 2 || entries[0].Comment != "blocked" || entries[0].Response.Status != 403 || entries[1].Comment != "" {
+		t.Errorf("entries %+v", entries)
+	}
+}
+
+func TestInjectHeader(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(backend(&hits))
+	defer srv.Close()
+	p, client := listen(t)
+
+	p.InjectHeader("X-Test", "injected")
+	req, _ := http.NewRequest("GET", srv.URL+"/", nil)
+	req.Header.Set("X-Test", "browser")
+	req.Header.Set("Proxy-Connection", "keep-alive")
+	_, body := get(t, client, req)
+
+	var seen map[string]string
+	json.Unmarshal([]byte(body), &seen)
+	if seen["header"] != "injected" {
+		t.Errorf("server got X-Test %q, want the injected value", seen["header"])
+	}
+	if seen["proxyConnection"] != "" {
+		t.Error("hop header forwarded")
+	}
+}
+
+/* HTTPS is intercepted with a certificate of the proxy CA and recorded like plain HTTP. */
+func TestHTTPS(t *testing.T) {
+	var hits int32
+	srv := httptest.NewTLSServer(backend(&hits))
+	defer srv.Close()
+	p, client := listen(t)
+
+	p.InjectHeader("X-Test", "tls")
+	req, _ := http.NewRequest("GET", srv.URL+"/secure", nil)
+	res, body := get(t, client, req)
+	if res.StatusCode != 200 || !strings.Contains(body, `"header":"tls"`) {
+		t.Fatalf("status %d, body %q", res.StatusCode, body)
+	}
+	if res.TLS == nil || res.TLS.PeerCertificates[0].Issuer.CommonName != "se/proxy CA" {
+		t.Error("response not signed by the proxy CA")
+	}
+	entries := p.HAR().Log.Entries
+	if len(entries) != 1 || entries[0].Request.URL != srv.URL+"/secure" {
+		t.Errorf("entries %+v", entries)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	gone := srv.URL
+	srv.Close()
+	p, client := listen(t)
+
+	req, _ := http.NewRequest("GET", gone+"/", nil)
+	if res, _ := get(t, client, req); res.StatusCode != http.StatusBadGateway {
+		t.Errorf("status %d, want 502", res.StatusCode)
+	}
+	if e := p.HAR().Log.Entries; len(e) != 1 || e[0].Response.Status != 502 || e[0].Comment == "" {
+		t.Errorf("entries %+v", e)
+	}
+}
@@ -0,0 +1,276 @@
+/* Record the wire traffic of a real session to a fixture file and replay it later, so page
+objects and the client itself can be tested without a browser:
+
+	rt, done, err := replay.Fixture("testdata/login.json")
+	...
+	defer done()
+	wd, err := selenium.NewRemoteClient(caps, "", selenium.HTTPClient(rt))
+
+Fixtures are recorded when SE_RECORD is set and replayed otherwise. */
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+/* Environment variable switching Fixture to recording. */
+const RecordEnv = "SE_RECORD"
+
+/* Recorded request and its reply. */
+type Interaction struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`              // Path template, see Template.
+	Request     json.RawMessage `json:"request,omitempty"` // Normalized body, see Normalize.
+	Status      int             `json:"status"`
+	ContentType string          `json:"contentType,omitempty"`
+	Response    json.RawMessage `json:"response,omitempty"` // JSON replies.
+	Text        string          `json:"text,omitempty"`     // Replies that are not JSON.
+}
+
+type fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+var (
+	sessionID = regexp.MustCompile(`^/session/[^/]+`)
+	elementID = regexp.MustCompile(`/(element|shadow|frame|window)/([^/]+)`)
+	keywords  = map[string]bool{"active": true, "parent": true, "rect": true, "new": true, "fullscreen": true, "maximize": true, "minimize": true}
+)
+
+/* Path template of a request url: the part from "/session" or "/status" on (the executor
+prefix is dropped), session and element ids replaced by {session} and {element}. */
+func Template(path string) string {
+	if i := strings.Index(path, "/session"); i >= 0 {
+		path = path[i:]
+	} else if i := strings.Index(path, "/status"); i >= 0 {
+		path = path[i:]
+	}
+	path = sessionID.ReplaceAllString(path, "/session/{session}")
+	return elementID.ReplaceAllStringFunc(path, func(s string) string {
+		m := elementID.FindStringSubmatch(s)
+		if keywords[m[2]] {
+			return s
+		}
+		return "/" + m[1] + "/{" + m[1] + "}"
+	})
+}
+
+/* Canonical form of a JSON body (sorted keys, no whitespace), other bodies are returned as is. */
+func Normalize(body []byte) []byte {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	var v interface{}
+	if json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+/* RoundTripper sending requests with Transport and recording them. */
+type Recorder struct {
+	Transport http.RoundTripper // nil means http.DefaultTransport.
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{Transport: transport}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	in := Interaction{
+		Method:      req.Method,
+		Path:        Template(req.URL.Path),
+		Status:      res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+	}
+	if n := Normalize(body); n != nil {
+		in.Request = json.RawMessage(n)
+		if !json.Valid(n) {
+			in.Request, _ = json.Marshal(string(n))
+		}
+	}
+	if json.Valid(data) {
+		in.Response = json.RawMessage(data)
+	} else {
+		in.Text = string(data)
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+	return res, nil
+}
+
+/* Recorded interactions so far. */
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+/* Write the recorded interactions to a fixture file. */
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(fixture{r.Interactions()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+/* RoundTripper answering requests from recorded interactions. A request gets the first unused
+interaction with the same method, path template and normalized body, so commands may be sent
+in a slightly different order than they were recorded. */
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+func NewReplayer(interactions []Interaction) *Replayer {
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions))}
+}
+
+/* Replayer of a fixture file written by Recorder.Save. */
+func Load(path string) (*Replayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := new(fixture)
+	if err = json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("replay: %s: %s", path, err)
+	}
+	return NewReplayer(f.Interactions), nil
+}
+
+func (p *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	path := Template(req.URL.Path)
+	request := Normalize(body)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, in := range p.interactions {
+		if p.used[i] || in.Method != req.Method || in.Path != path || !sameBody(in.Request, request) {
+			continue
+		}
+		p.used[i] = true
+
+		data := []byte(in.Text)
+		if in.Response != nil {
+			data = in.Response
+		}
+		header := http.Header{}
+		if in.ContentType != "" {
+			header.Set("Content-Type", in.ContentType)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("replay: no recorded %s %s with body %s", req.Method, path, request)
+}
+
+/* Interactions not replayed yet, a test replaying a whole fixture expects none. */
+func (p *Replayer) Unused() []Interaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var unused []Interaction
+	for i, in := range p.interactions {
+		if !p.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+/* Recorder or Replayer for the fixture at path, depending on SE_RECORD. done saves the
+recording, or reports the interactions that were not replayed. */
+func Fixture(path string) (rt http.RoundTripper, done func() error, err error) {
+	if os.Getenv(RecordEnv) != "" {
+		r := NewRecorder(nil)
+		return r, func() error { return r.Save(path) }, nil
+	}
+
+	p, err := Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, func() error {
+		if unused := p.Unused(); len(unused) > 0 {
+			return fmt.Errorf("replay: %d recorded commands not sent, first %s %s", len(unused), unused[0].Method, unused[0].Path)
+		}
+		return nil
+	}, nil
+}
+
+/* Read the request body and leave a fresh copy for the transport. */
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func sameBody(recorded json.RawMessage, request []byte) bool {
+	if recorded == nil || request == nil {
+		return recorded == nil && request == nil
+	}
+	if json.Valid(request) {
+		return bytes.Equal(Normalize(recorded), request)
+	}
+	var s string
+	return json.Unmarshal(recorded, &s) == nil && s == string(request)
+}
@@ -0,0 +1,144 @@
+package replay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"se/selenium"
+	"strings"
+	"testing"
+)
+
+/* Session in the JSON wire dialect of chromedriver 2.46: new session, navigation, finding an
+element and its text, a script, a missing element and quit. */
+const sessionFixture = "testdata/chromedriver-session.json"
+
+func TestReplaySession(t *testing.T) {
+	executor := "http://127.0.0.1:9515/wd/hub"
+	p, err := Load(sessionFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := selenium.NewRemoteClient(selenium.Capabilities{"browserName": "chrome"}, executor, selenium.HTTPClient(p))
+	if err != nil {
+		t.Fatalf("new session: %s", err)
+	}
+	wd.SetDebug(false)
+	if wd.SessionId() != "8d4c1f0b3e2a6f9d7c5b4a3e2d1c0b9a" {
+		t.Errorf("session id %q", wd.SessionId())
+	}
+	if err = wd.Get("http://app.local/login"); err != nil {
+		t.Fatalf("get: %s", err)
+	}
+	e, err := wd.FindElement("css selector", "#welcome")
+	if err != nil {
+		t.Fatalf("find element: %s", err)
+	}
+	if text, err := e.Text(); err != nil || text != "Welcome, bob" {
+		t.Errorf("text %q (%v)", text, err)
+	}
+
+	script := "return {title: document.title, items: arguments[0].length};"
+	v, err := wd.ExecuteScript(script, []interface{}{[]int{1, 2, 3}})
+	if err != nil {
+		t.Fatalf("execute script: %s", err)
+	}
+	m, _ := v.(map[string]interface{})
+	if m["title"] != "Settings" || m["items"] != float64(3) {
+		t.Errorf("script result %v", v)
+	}
+
+	_, err = wd.FindElement("css selector", "#missing")
+	if q, ok := err.(*selenium.QueryError); !ok || q.Status != 7 {
+		t.Errorf("missing element: got %v, want status 7", err)
+	}
+
+	if err = wd.Quit(); err != nil {
+		t.Errorf("quit: %s", err)
+	}
+	if unused := p.Unused(); len(unused) != 0 {
+		t.Errorf("%d interactions not replayed, first %s %s", len(unused), unused[0].Method, unused[0].Path)
+	}
+}
+
+func TestReplayUnknownCommand(t *testing.T) {
+	p, err := Load(sessionFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:9515/wd/hub/session/x/title", nil)
+	if _, err = p.RoundTrip(req); err == nil {
+		t.Error("replayed a command that was never recorded")
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/session":
+			w.Write([]byte(`{"sessionId":"live-1","status":0,"value":{"browserName":"firefox"}}`))
+		case strings.HasSuffix(r.URL.Path, "/element"):
+			w.Write([]byte(`{"sessionId":"live-1","status":0,"value":{"ELEMENT":"e-17"}}`))
+		default:
+			w.Write([]byte(`{"sessionId":"live-1","status":0,"value":"Hello"}`))
+		}
+	}))
+	defer srv.Close()
+
+	rec := NewRecorder(nil)
+	wd, err := selenium.NewRemoteClient(selenium.Capabilities{"browserName": "firefox"}, srv.URL, selenium.HTTPClient(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd.SetDebug(false)
+	e, err := wd.FindElement("id", "greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Text()
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err = rec.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Another executor and other ids: only path templates and bodies are compared. */
+	wd, err = selenium.NewRemoteClient(selenium.Capabilities{"browserName": "firefox"}, "http://elsewhere:4444/wd/hub", selenium.HTTPClient(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd.SetDebug(false)
+	if e, err = wd.FindElement("id", "greeting"); err != nil {
+		t.Fatal(err)
+	}
+	if text, err := e.Text(); err != nil || text != "Hello" {
+		t.Errorf("text %q (%v)", text, err)
+	}
+	if unused := p.Unused(); len(unused) != 0 {
+		t.Errorf("%d interactions not replayed", len(unused))
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	for path, want := range map[string]string{
+		"/wd/hub/session":                           "/session",
+		"/wd/hub/session/abc/element/0.1-2/text":    "/session/{session}/element/{element}/text",
+		"/session/abc/element/active":               "/session/{session}/element/active",
+		"/session/abc/window/current/maximize":      "/session/{session}/window/{window}/maximize",
+		"/session/abc/window/maximize":              "/session/{session}/window/maximize",
+		"/wd/hub/status":                            "/status",
+		"/session/abc/frame/parent":                 "/session/{session}/frame/parent",
+		"/session/abc/shadow/s-1/element":           "/session/{session}/shadow/{shadow}/element",
+		"/session/abc/element/e-1/element/e-2/text": "/session/{session}/element/{element}/element/{element}/text",
+	} {
+		if got := Template(path); got != want {
+			t.Errorf("Template(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package se
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/* Handler of a route: the mocked response, see Page.Route. Routes are declared in Go but
+answered in the page, so handlers are data rather than functions. */
+type RouteHandler struct {
+	Method  string            `json:"-"`      // Answer only requests of this HTTP method, empty means any.
+	Status  int               `json:"status"` // 200 if 0.
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	Delay   time.Duration     `json:"-"` // Delay before the response is delivered.
+}
+
+/* Request answered by a mocked route. */
+type RouteHit struct {
+	Pattern   string
+	Method    string
+	URL       string
+	Timestamp time.Time
+}
+
+type route struct {
+	Pattern string       `json:"pattern"`
+	Regexp  string       `json:"regexp"`
+	Method  string       `json:"method"`
+	Delay   int64        `json:"delay"` // Milliseconds.
+	Reply   RouteHandler `json:"response"`
+}
+
+/* The shim looks rules up in window.__seRoutes.rules, answers matching XHR/fetch requests
+itself and passes everything else to the real implementations. Hits are parked in
+sessionStorage across document loads. */
+const routeScript = `
+var rules = arguments[0], key = '__seRoutes';
+if (window.__seRoutes) { window.__seRoutes.rules = rules; return; }
+var state = {rules: rules, hits: []};
+try { state.hits = JSON.parse(window.sessionStorage.getItem(key)) || []; window.sessionStorage.removeItem(key); } catch (e) {}
+window.__seRoutes = state;
+var lookup = function(method, url) {
+	url = String(url);
+	try { url = new URL(url, window.location.href).href; } catch (e) {}
+	method = (method || 'GET').toUpperCase();
+	for (var i = 0; i < state.rules.length; i++) {
+		var r = state.rules[i];
+		if ((!r.method || r.method === method) && new RegExp(r.regexp).test(url)) {
+			state.hits.push({pattern: r.pattern, method: method, url: url, timestamp: Date.now()});
+			return r;
+		}
+	}
+	return null;
+};
+var status = function(r) { return r.response.status || 200; };
+var nullBody = function(r) { var s = status(r); return s === 204 || s === 205 || s === 304; };
+var headers = function(r) { return r.response.headers || {}; };
+
+if (window.fetch) {
+	var fetch = window.fetch;
+	window.fetch = function(input, init) {
+		var method = init && init.method || input && input.method;
+		var r = lookup(method, input && input.url || input);
+		if (!r) { return fetch.apply(this, arguments); }
+		return new Promise(function(resolve, reject) {
+			setTimeout(function() {
+				try {
+					resolve(new Response(nullBody(r) ? null : r.response.body, {status: status(r), headers: headers(r)}));
+				} catch (e) { reject(e); }
+			}, r.delay);
+		});
+	};
+}
+
+var open = XMLHttpRequest.prototype.open, send = XMLHttpRequest.prototype.send;
+var setHeader = XMLHttpRequest.prototype.setRequestHeader;
+XMLHttpRequest.prototype.open = function(method, url) {
+	this.__seRoute = lookup(method, url);
+	if (!this.__seRoute) { return open.apply(this, arguments); }
+};
+XMLHttpRequest.prototype.setRequestHeader = function() {
+	if (!this.__seRoute) { return setHeader.apply(this, arguments); }
+};
+XMLHttpRequest.prototype.send = function() {
+	var xhr = this, r = xhr.__seRoute;
+	if (!r) { return send.apply(xhr, arguments); }
+	var define = function(name, value) { Object.defineProperty(xhr, name, {value: value, configurable: true}); };
+	setTimeout(function() {
+		var h = headers(r), lines = [];
+		for (var k in h) { lines.push(k + ': ' + h[k]); }
+		define('readyState', 4);
+		define('status', status(r));
+		define('statusText', String(status(r)));
+		define('responseText', r.response.body);
+		define('response', r.response.body);
+		define('responseURL', '');
+		xhr.getAllResponseHeaders = function() { return lines.join('\r\n'); };
+		xhr.getResponseHeader = function(name) {
+			for (var k in h) { if (k.toLowerCase() === String(name).toLowerCase()) { return h[k]; } }
+			return null;
+		};
+		xhr.dispatchEvent(new Event('readystatechange'));
+		xhr.dispatchEvent(new ProgressEvent('load'));
+		xhr.dispatchEvent(new ProgressEvent('loadend'));
+	}, r.delay);
+};
+window.addEventListener('pagehide', function() {
+	try { window.sessionStorage.setItem(key, JSON.stringify(state.hits)); } catch (e) {}
+});`
+
+const routeHitsScript = `
+return window.__seRoutes ? window.__seRoutes.hits : [];`
+
+const clearRouteHitsScript = `
+if (window.__seRoutes) { window.__seRoutes.hits = []; }`
+
+/* Turn a URL pattern into a regular expression: "*" matches anything but "/", "**" matches
+anything. Patterns without a scheme match the end of the URL, so "/api/status" matches any
+host; patterns starting with "^" are used as regular expressions as they are. */
+func routeRegexp(pattern string) string {
+	if strings.HasPrefix(pattern, "^") {
+		return pattern
+	}
+	var re string
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			re += ".*"
+			i++
+		case pattern[i] == '*':
+			re += "[^/]*"
+		default:
+			re += regexp.QuoteMeta(pattern[i : i+1])
+		}
+	}
+	if strings.Contains(pattern, "://") {
+		return "^" + re + "([?#].*)?$"
+	}
+	return re + "([?#].*)?$"
+}
+
+/* Answer XHR/fetch requests matching pattern (see routeRegexp) with the handler's mocked
+response, all other requests pass through. Routes are re-injected into every document the
+page loads, including those loaded by clicks and form submits; a route for the same pattern
+and method replaces the previous one, routes added first win. */
+func (p *Page) Route(pattern string, handler RouteHandler) error {
+	r := route{
+		Pattern: pattern,
+		Regexp:  routeRegexp(pattern),
+		Method:  strings.ToUpper(handler.Method),
+		Delay:   int64(handler.Delay / time.Millisecond),
+		Reply:   handler,
+	}
+
+	replaced := false
+	for i, old := range p.routes {
+		if old.Pattern == r.Pattern && old.Method == r.Method {
+			p.routes[i], replaced = r, true
+		}
+	}
+	if !replaced {
+		p.routes = append(p.routes, r)
+	}
+	return p.injectRoutes()
+}
+
+/* Remove the routes of pattern. */
+func (p *Page) Unroute(pattern string) error {
+	routes := p.routes[:0]
+	for _, r := range p.routes {
+		if r.Pattern != pattern {
+			routes = append(routes, r)
+		}
+	}
+	p.routes = routes
+	return p.injectRoutes()
+}
+
+func (p *Page) injectRoutes() error {
+	source, err := routeSource(p.routes)
+	if err != nil {
+		return err
+	}
+	return p.injectNow("route", source)
+}
+
+/* The rules are baked into the source so that re-injection after navigation needs no
+arguments. */
+func routeSource(routes []route) (string, error) {
+	if routes == nil {
+		routes = []route{} // The shim expects an array, not null.
+	}
+	data, err := json.Marshal(routes)
+	if err != nil {
+		return "", err
+	}
+	return "return (function() {" + routeScript + "}).apply(this, [" + string(data) + "]);", nil
+}
+
+/* Requests answered by routes since the routes were added or the hits were cleared. */
+func (p *Page) RouteHits() ([]RouteHit, error) {
+	data, err := p.webDriver.ExecuteScriptRaw(routeHitsScript, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := new(struct {
+		Value []struct {
+			Pattern   string  `json:"pattern"`
+			Method    string  `json:"method"`
+			URL       string  `json:"url"`
+			Timestamp float64 `json:"timestamp"`
+		}
+	})
+	if err = json.Unmarshal(data, reply); err != nil {
+		return nil, err
+	}
+
+	hits := make([]RouteHit, len(reply.Value))
+	for i, h := range reply.Value {
+		hits[i] = RouteHit{Pattern: h.Pattern, Method: h.Method, URL: h.URL, Timestamp: msTime(h.Timestamp)}
+	}
+	return hits, nil
+}
+
+/* Forget the route hits seen so far. */
+func (p *Page) ClearRouteHits() error {
+	_, err := p.webDriver.ExecuteScript(clearRouteHitsScript, nil)
+	return err
+}
@@ -0,0 +1,58 @@
+package se
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+/* Minimal window for the route shim in node: fetch, URL and Response are node's own, requests
+that are not mocked fail. */
+const nodeWindow = `
+globalThis.window = globalThis;
+window.location = {href: 'http://app.local/'};
+window.sessionStorage = {getItem: function() { return null; }, setItem: function() {}, removeItem: function() {}};
+window.addEventListener = function() {};
+globalThis.XMLHttpRequest = function() {};
+window.fetch = function() { return Promise.reject(new Error('not mocked')); };
+`
+
+/* Run the shim with routes in node and fetch url, the output is the status and body. */
+func nodeFetch(t *testing.T, routes []route, method, url string) string {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not installed")
+	}
+	source, err := routeSource(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := nodeWindow + "(function() {" + source + "})();\n" +
+		"fetch('" + url + "', {method: '" + method + "'}).then(function(r) {\n" +
+		"	return r.text().then(function(body) { console.log(r.status, r.body === null ? 'null' : JSON.stringify(body)); });\n" +
+		"}, function(e) { console.log('rejected:', e.message); });\n" +
+		"setTimeout(function() { console.log('unsettled'); process.exit(); }, 2000).unref();\n"
+	out, err := exec.Command(node, "-e", program).CombinedOutput()
+	if err != nil {
+		t.Fatalf("node: %s\n%s", err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestRouteFetchStatus(t *testing.T) {
+	for _, c := range []struct {
+		handler RouteHandler
+		want    string
+	}{
+		{RouteHandler{Body: `{"ok":true}`}, `200 "{\"ok\":true}"`},
+		{RouteHandler{Status: 204}, `204 null`},
+		{RouteHandler{Status: 304, Body: "ignored"}, `304 null`},
+		{RouteHandler{Status: 404, Body: "gone"}, `404 "gone"`},
+	} {
+		routes := []route{{Pattern: "/api/items", Regexp: routeRegexp("/api/items"), Reply: c.handler}}
+		if got := nodeFetch(t, routes, "DELETE", "/api/items"); got != c.want {
+			t.Errorf("status %d: got %s, want %s", c.handler.Status, got, c.want)
+		}
+	}
+}
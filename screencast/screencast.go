@@ -0,0 +1,248 @@
+/* Screencast of a page: screenshots sampled in the background at a fixed frame rate, encoded
+as an animated GIF with the current step as caption.
+
+	rec := screencast.Start(&page.Page, screencast.Options{})
+	...
+	rec.Finish("login.gif", t.Failed())
+*/
+package screencast
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"se"
+	"sync"
+	"time"
+)
+
+/* Returned when writing a screencast without frames, e.g. the browser never answered. */
+var ErrNoFrames = errors.New("screencast: no frames captured")
+
+/* Recording options, zero values mean the defaults. */
+type Options struct {
+	FPS       float64 // Screenshots per second, 2 by default.
+	Scale     float64 // Size of the frames relative to the screenshots, 0.5 by default.
+	MaxFrames int     // Older frames are dropped beyond this, 600 by default.
+	Captions  bool    // Draw the step in progress below the frames.
+}
+
+type frame struct {
+	img  *image.Paletted
+	time time.Time
+}
+
+/* Screencast in progress, also the step observer providing the captions. */
+type Recorder struct {
+	page *se.Page
+	opts Options
+
+	mu      sync.Mutex
+	frames  []frame
+	caption string
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+/* Start sampling screenshots of p. Screenshots are taken on their own goroutine, they only
+wait for the command the test is running (commands of a session are serialized), and ticks
+are skipped while a screenshot is still being taken. */
+func Start(p *se.Page, opts Options) *Recorder {
+	if opts.FPS <= 0 {
+		opts.FPS = 2
+	}
+	if opts.Scale <= 0 {
+		opts.Scale = 0.5
+	}
+	if opts.MaxFrames <= 0 {
+		opts.MaxFrames = 600
+	}
+
+	r := &Recorder{page: p, opts: opts, stop: make(chan struct{}), done: make(chan struct{})}
+	if opts.Captions {
+		p.Observe(r)
+	}
+	go r.sample()
+	return r
+}
+
+func (r *Recorder) BeforeStep(p *se.Page, s *se.Step) {
+	r.mu.Lock()
+	r.caption = s.String()
+	r.mu.Unlock()
+}
+
+func (r *Recorder) AfterStep(p *se.Page, s *se.Step) {
+	if s.Err == nil {
+		return
+	}
+	r.mu.Lock()
+	r.caption = s.String() + " - FAILED"
+	r.mu.Unlock()
+}
+
+func (r *Recorder) sample() {
+	defer close(r.done)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / r.opts.FPS))
+	defer ticker.Stop()
+
+	r.capture()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.capture()
+		}
+	}
+}
+
+func (r *Recorder) capture() {
+	data, err := r.page.ScreenshotPNG()
+	if err != nil {
+		return
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	caption := r.caption
+	r.mu.Unlock()
+
+	f := frame{paletted(r.render(img, caption)), now}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.frames) >= r.opts.MaxFrames {
+		r.frames = r.frames[1:]
+	}
+	r.frames = append(r.frames, f)
+}
+
+/* Scaled screenshot with the caption bar below it. */
+func (r *Recorder) render(img image.Image, caption string) *image.RGBA {
+	b := img.Bounds()
+	w := int(float64(b.Dx()) * r.opts.Scale)
+	h := int(float64(b.Dy()) * r.opts.Scale)
+	if w < 1 || h < 1 {
+		w, h = 1, 1
+	}
+
+	textScale, bar := 0, 0
+	if r.opts.Captions {
+		textScale = w / 400
+		if textScale < 1 {
+			textScale = 1
+		}
+		bar = (glyphHeight + 4) * textScale
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h+bar))
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + y*b.Dy()/h
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*b.Dx()/w, sy))
+		}
+	}
+	if bar > 0 {
+		draw.Draw(dst, image.Rect(0, h, w, h+bar), image.Black, image.ZP, draw.Src)
+		drawText(dst, 2*textScale, h+2*textScale, textScale, caption, color.White)
+	}
+	return dst
+}
+
+func paletted(img image.Image) *image.Paletted {
+	p := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.FloydSteinberg.Draw(p, img.Bounds(), img, image.ZP)
+	return p
+}
+
+/* Stop sampling, frames captured so far are kept. */
+func (r *Recorder) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+/* Number of frames captured so far. */
+func (r *Recorder) Frames() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.frames)
+}
+
+/* Encode the frames as an animated GIF, frames are shown as long as they lasted and identical
+frames are merged. */
+func (r *Recorder) WriteGIF(w io.Writer) error {
+	r.mu.Lock()
+	frames := append([]frame(nil), r.frames...)
+	r.mu.Unlock()
+
+	anim := &gif.GIF{}
+	for i, f := range frames {
+		end := time.Now()
+		if i+1 < len(frames) {
+			end = frames[i+1].time
+		}
+		delay := int(end.Sub(f.time) / (10 * time.Millisecond))
+		if delay < 1 {
+			delay = 1
+		}
+
+		if n := len(anim.Image); n > 0 && samePixels(anim.Image[n-1], f.img) {
+			anim.Delay[n-1] += delay
+			continue
+		}
+		anim.Image = append(anim.Image, f.img)
+		anim.Delay = append(anim.Delay, delay)
+		/* The window may have been resized, the logical screen fits all frames. */
+		if b := f.img.Bounds(); b.Dx() > anim.Config.Width {
+			anim.Config.Width = b.Dx()
+		}
+		if b := f.img.Bounds(); b.Dy() > anim.Config.Height {
+			anim.Config.Height = b.Dy()
+		}
+	}
+	if len(anim.Image) == 0 {
+		return ErrNoFrames
+	}
+	anim.Config.ColorModel = color.Palette(palette.Plan9)
+	return gif.EncodeAll(w, anim)
+}
+
+/* Write the screencast to path. */
+func (r *Recorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = r.WriteGIF(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+/* Stop, and save the screencast to path only if the test failed. */
+func (r *Recorder) Finish(path string, failed bool) error {
+	r.Stop()
+	if !failed {
+		return nil
+	}
+	return r.Save(path)
+}
+
+func samePixels(a, b *image.Paletted) bool {
+	return a.Bounds() == b.Bounds() && bytes.Equal(a.Pix, b.Pix)
+}
@@ -3,6 +3,7 @@ package se
 
 import (
 	"se/selenium"
+	"time"
 )
 
 /* Element selectors */
@@ -42,14 +43,15 @@ const (
 
 /* Keys */
 const (
-	BackspaceKey = string(0x8)
-	TabKey       = string(0x9)
+	BackspaceKey = string(rune(0x8))
+	TabKey       = string(rune(0x9))
 	ClearKey     = string('\ue005')
-	ReturnKey    = string(0x0A)
-	EnterKey     = string(0x0A)
+	ReturnKey    = string(rune(0x0A))
+	EnterKey     = string(rune(0x0A))
 )
 
 /* Unexported global variables. */
 var (
-	wd selenium.WebDriver
+	wd           selenium.WebDriver
+	pollInterval = 100 * time.Millisecond // Interval of the wait loops.
 )
@@ -0,0 +1,360 @@
+package selenium
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"se/websocket"
+	"sync"
+	"time"
+)
+
+/* BiDi event names */
+const (
+	EventLogEntryAdded     = "log.entryAdded"
+	EventContextLoad       = "browsingContext.load"
+	EventBeforeRequestSent = "network.beforeRequestSent"
+)
+
+/* Size of the event channels, events are dropped for receivers that fall behind. */
+const bidiBuffer = 256
+
+/* Returned by BiDi commands once the connection is closed. */
+var ErrBiDiClosed = errors.New("bidi: connection closed")
+
+/* Error reply of a BiDi command. */
+type BiDiError struct {
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *BiDiError) Error() string {
+	return fmt.Sprintf("bidi: %s: %s", e.Code, e.Message)
+}
+
+/* log.entryAdded event */
+type BiDiLogEntry struct {
+	Type      string // "console" or "javascript".
+	Level     string // "debug", "info", "warn" or "error".
+	Text      string
+	Method    string // Console method, for console entries.
+	Context   string // Browsing context (window or frame) id.
+	Timestamp time.Time
+}
+
+/* browsingContext.load event */
+type BiDiLoad struct {
+	Context    string
+	Navigation string
+	URL        string
+	Timestamp  time.Time
+}
+
+/* network.beforeRequestSent event */
+type BiDiRequest struct {
+	Context       string
+	Navigation    string
+	RequestID     string
+	URL           string
+	Method        string
+	RedirectCount int
+	Timestamp     time.Time
+}
+
+type bidiMessage struct {
+	ID      int64           `json:"id"`
+	Type    string          `json:"type"` // "success", "error" or "event".
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Result  json.RawMessage `json:"result"`
+	Error   string          `json:"error"`
+	Message string          `json:"message"`
+}
+
+type bidiSubscriber struct {
+	deliver func(params json.RawMessage)
+	close   func()
+}
+
+/* WebDriver BiDi connection, see https://w3c.github.io/webdriver-bidi/ */
+type BiDi struct {
+	conn *websocket.Conn
+
+	mu         sync.Mutex
+	nextID     int64
+	pending    map[int64]chan *bidiMessage
+	subs       map[string][]*bidiSubscriber
+	subscribed map[string]bool // Events subscribed with session.subscribe.
+	closed     bool
+	done       chan struct{}
+}
+
+func (wd *remoteWD) BiDi() (*BiDi, error) {
+	wd.mu.Lock()
+	b := wd.bidi
+	endpoint, _ := wd.sessionCaps["webSocketUrl"].(string)
+	wd.mu.Unlock()
+	if b != nil {
+		return b, nil
+	}
+	if endpoint == "" {
+		return nil, errors.New(`bidi: the session has no webSocketUrl, request it with the "webSocketUrl": true capability`)
+	}
+
+	/* Dial without holding wd.mu, a concurrent call may connect too: the first one stored
+	wins. */
+	b, err := DialBiDi(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	wd.mu.Lock()
+	stored := wd.bidi
+	if stored == nil {
+		wd.bidi = b
+	}
+	wd.mu.Unlock()
+	if stored != nil {
+		b.Close()
+		return stored, nil
+	}
+	return b, nil
+}
+
+/* Connect to a BiDi WebSocket endpoint. Sessions usually get theirs through WebDriver.BiDi. */
+func DialBiDi(endpoint string) (*BiDi, error) {
+	conn, err := websocket.Dial(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BiDi{
+		conn:       conn,
+		pending:    map[int64]chan *bidiMessage{},
+		subs:       map[string][]*bidiSubscriber{},
+		subscribed: map[string]bool{},
+		done:       make(chan struct{}),
+	}
+	go b.read()
+	return b, nil
+}
+
+/* Send a command and wait for its result. */
+func (b *BiDi) Send(method string, params interface{}) (json.RawMessage, error) {
+	if params == nil {
+		params = struct{}{}
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrBiDiClosed
+	}
+	b.nextID++
+	id := b.nextID
+	reply := make(chan *bidiMessage, 1)
+	b.pending[id] = reply
+	b.mu.Unlock()
+
+	data, err := json.Marshal(map[string]interface{}{"id": id, "method": method, "params": params})
+	if err == nil {
+		err = b.conn.WriteMessage(data)
+	}
+	if err != nil {
+		b.mu.Lock()
+		delete(b.pending, id)
+		b.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case r := <-reply:
+		if r.Type == "error" {
+			return nil, &BiDiError{Code: r.Error, Message: r.Message}
+		}
+		return r.Result, nil
+	case <-b.done:
+		return nil, ErrBiDiClosed
+	}
+}
+
+/* Register a subscriber and subscribe the session to the event on first use. */
+func (b *BiDi) subscribe(event string, deliver func(params json.RawMessage), closer func()) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		closer()
+		return ErrBiDiClosed
+	}
+	sub := &bidiSubscriber{deliver, closer}
+	b.subs[event] = append(b.subs[event], sub)
+	first := !b.subscribed[event]
+	b.subscribed[event] = true
+	b.mu.Unlock()
+
+	if !first {
+		return nil
+	}
+	_, err := b.Send("session.subscribe", map[string]interface{}{"events": []string{event}})
+	if err != nil {
+		b.mu.Lock()
+		b.subscribed[event] = false
+		subs := b.subs[event]
+		for i, s := range subs {
+			if s == sub {
+				b.subs[event] = append(subs[:i], subs[i+1:]...)
+			}
+		}
+		b.mu.Unlock()
+		closer()
+	}
+	return err
+}
+
+/* Raw event parameters of event until the session ends. */
+func (b *BiDi) Events(event string) (<-chan json.RawMessage, error) {
+	ch := make(chan json.RawMessage, bidiBuffer)
+	err := b.subscribe(event, func(params json.RawMessage) {
+		select {
+		case ch <- params:
+		default:
+		}
+	}, func() { close(ch) })
+	return ch, err
+}
+
+/* Console messages and JavaScript errors until the session ends. */
+func (b *BiDi) LogEntries() (<-chan BiDiLogEntry, error) {
+	ch := make(chan BiDiLogEntry, bidiBuffer)
+	err := b.subscribe(EventLogEntryAdded, func(params json.RawMessage) {
+		e := new(struct {
+			Type   string `json:"type"`
+			Level  string `json:"level"`
+			Text   string `json:"text"`
+			Method string `json:"method"`
+			Source struct {
+				Context string `json:"context"`
+			} `json:"source"`
+			Timestamp float64 `json:"timestamp"`
+		})
+		if json.Unmarshal(params, e) != nil {
+			return
+		}
+		select {
+		case ch <- BiDiLogEntry{e.Type, e.Level, e.Text, e.Method, e.Source.Context, bidiTime(e.Timestamp)}:
+		default:
+		}
+	}, func() { close(ch) })
+	return ch, err
+}
+
+/* Completed page loads until the session ends. */
+func (b *BiDi) Loads() (<-chan BiDiLoad, error) {
+	ch := make(chan BiDiLoad, bidiBuffer)
+	err := b.subscribe(EventContextLoad, func(params json.RawMessage) {
+		e := new(struct {
+			Context    string  `json:"context"`
+			Navigation string  `json:"navigation"`
+			URL        string  `json:"url"`
+			Timestamp  float64 `json:"timestamp"`
+		})
+		if json.Unmarshal(params, e) != nil {
+			return
+		}
+		select {
+		case ch <- BiDiLoad{e.Context, e.Navigation, e.URL, bidiTime(e.Timestamp)}:
+		default:
+		}
+	}, func() { close(ch) })
+	return ch, err
+}
+
+/* Requests about to be sent until the session ends. */
+func (b *BiDi) Requests() (<-chan BiDiRequest, error) {
+	ch := make(chan BiDiRequest, bidiBuffer)
+	err := b.subscribe(EventBeforeRequestSent, func(params json.RawMessage) {
+		e := new(struct {
+			Context       string `json:"context"`
+			Navigation    string `json:"navigation"`
+			RedirectCount int    `json:"redirectCount"`
+			Request       struct {
+				Request string `json:"request"`
+				URL     string `json:"url"`
+				Method  string `json:"method"`
+			} `json:"request"`
+			Timestamp float64 `json:"timestamp"`
+		})
+		if json.Unmarshal(params, e) != nil {
+			return
+		}
+		r := BiDiRequest{
+			Context:       e.Context,
+			Navigation:    e.Navigation,
+			RequestID:     e.Request.Request,
+			URL:           e.Request.URL,
+			Method:        e.Request.Method,
+			RedirectCount: e.RedirectCount,
+			Timestamp:     bidiTime(e.Timestamp),
+		}
+		select {
+		case ch <- r:
+		default:
+		}
+	}, func() { close(ch) })
+	return ch, err
+}
+
+/* Close the connection and all event channels. */
+func (b *BiDi) Close() error {
+	err := b.conn.Close()
+	<-b.done
+	return err
+}
+
+/* Closed once the connection is gone. */
+func (b *BiDi) Done() <-chan struct{} {
+	return b.done
+}
+
+func (b *BiDi) read() {
+	defer b.shutdown()
+	for {
+		data, err := b.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		msg := new(bidiMessage)
+		if json.Unmarshal(data, msg) != nil {
+			continue
+		}
+
+		b.mu.Lock()
+		if msg.Type == "event" {
+			for _, s := range b.subs[msg.Method] {
+				s.deliver(msg.Params)
+			}
+		} else if reply, ok := b.pending[msg.ID]; ok {
+			delete(b.pending, msg.ID)
+			reply <- msg
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *BiDi) shutdown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for event, subs := range b.subs {
+		for _, s := range subs {
+			s.close()
+		}
+		delete(b.subs, event)
+	}
+	close(b.done)
+}
+
+/* BiDi timestamps are milliseconds since the epoch. */
+func bidiTime(ms float64) time.Time {
+	return time.Unix(0, int64(ms*float64(time.Millisecond)))
+}
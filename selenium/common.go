@@ -2,28 +2,40 @@ package selenium
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"gtf/drivers/log"
-	"os"
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
 	"time"
 )
 
-var debugFlag = true
+/* Debug logging of drivers created from now on, see WebDriver.SetDebug. */
+var DefaultDebug = true
 
-func setDebug(debug bool) {
-	debugFlag = debug
+func (wd *remoteWD) SetDebug(debug bool) {
+	wd.mu.Lock()
+	wd.debug = debug
+	wd.mu.Unlock()
 }
 
-func debugLog(format string, args ...interface{}) {
-	if !debugFlag {
+func (wd *remoteWD) debugging() bool {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	return wd.debug
+}
+
+func (wd *remoteWD) debugLog(format string, args ...interface{}) {
+	if !wd.debugging() {
 		return
 	}
 	log.Textf(format+"\n", args...)
 	time.Sleep(10 * time.Microsecond)
 }
 
-func warningLog(format string, args ...interface{}) {
-	if !debugFlag {
+func (wd *remoteWD) warningLog(format string, args ...interface{}) {
+	if !wd.debugging() {
 		return
 	}
 	log.Warningf(format+"\n", args...)
@@ -31,28 +43,88 @@ func warningLog(format string, args ...interface{}) {
 }
 
 type QueryError struct {
-	Status  int
-	Message string
+	Status     int
+	Message    string
+	Node       string // Grid node that ran the command, if known.
+	HTTPStatus int    // Status code of error replies (400 and above), 0 otherwise.
+	Code       string // W3C error code, e.g. "unknown command", empty for JSON wire replies.
 }
 
 func (e QueryError) Error() string {
+	if e.Node != "" {
+		return fmt.Sprintf(`{"status":%d, "message":"%s", "node":"%s"}`, e.Status, e.Message, e.Node)
+	}
 	return fmt.Sprintf(`{"status":%d, "message":"%s"}`, e.Status, e.Message)
 }
 
-func logScreenShot(data *string) (string, error) {
-	image := fmt.Sprintf("%d.png", time.Now().Unix())
-	dstFile, err := os.Create(image)
-	if err != nil {
-		fmt.Println(err.Error())
-		return "", err
+/* Error of a reply with status, tagged with the grid node running the session. */
+func (wd *remoteWD) statusError(status int) *QueryError {
+	message, ok := errors_[status]
+	if !ok {
+		message = fmt.Sprintf("unknown error - %d", status)
+	}
+	return &QueryError{Status: status, Message: message, Node: wd.Node()}
+}
+
+/* Error of a reply with HTTP status code 400 or above. W3C servers don't send a status, they
+name the error in the value, e.g. {"value":{"error":"unknown command","message":"..."}}. */
+func (wd *remoteWD) httpError(code int, reply *serverReply) error {
+	err := wd.statusError(reply.Status)
+	err.HTTPStatus = code
+	var w3c struct {
+		Error string `json:"error"`
+	}
+	if reply.Status == SUCCESS && json.Unmarshal(reply.Value, &w3c) == nil && w3c.Error != "" {
+		err.Code, err.Message = w3c.Error, w3c.Error
+	}
+	return err
+}
+
+/* Where the screenshots returned by Screenshot, and those the server attaches to error
+replies, are stored. The zero value writes "<unix time>-<sequence>.png" files into the
+current directory. */
+type ScreenshotSink struct {
+	Dir      string                               // Directory of the PNG files, empty means the current directory.
+	Name     func(seq uint64, t time.Time) string // File naming scheme, DefaultScreenshotName if nil.
+	Callback func(path string, png []byte)        // Called for every screenshot, path is empty if Discard is set.
+	Discard  bool                                 // Don't write files, only call Callback.
+}
+
+/* Sink used by drivers without their own one, see WebDriver.SetScreenshotSink. */
+var DefaultScreenshotSink = &ScreenshotSink{}
+
+var screenshotSeq uint64
+
+/* Default file naming scheme, the sequence number keeps screenshots taken within the same
+second apart. */
+func DefaultScreenshotName(seq uint64, t time.Time) string {
+	return fmt.Sprintf("%d-%d.png", t.Unix(), seq)
+}
+
+/* Store PNG data, return the file name (empty if the sink discards files). */
+func (s *ScreenshotSink) Store(png []byte) (string, error) {
+	var image string
+	if !s.Discard {
+		name := s.Name
+		if name == nil {
+			name = DefaultScreenshotName
+		}
+		image = filepath.Join(s.Dir, name(atomic.AddUint64(&screenshotSeq, 1), time.Now()))
+		if err := ioutil.WriteFile(image, png, 0644); err != nil {
+			return "", err
+		}
+		log.ToggleImage("Screenshot Image", image, "off")
+	}
+	if s.Callback != nil {
+		s.Callback(image, png)
 	}
-	defer dstFile.Close()
+	return image, nil
+}
 
+func logScreenShot(sink *ScreenshotSink, data *string) (string, error) {
 	d, err := base64.StdEncoding.DecodeString(*data)
 	if err != nil {
 		return "", err
 	}
-	dstFile.Write(d)
-	log.ToggleImage("Screenshot Image", image, "off")
-	return image, nil
+	return sink.Store(d)
 }
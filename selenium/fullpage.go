@@ -0,0 +1,186 @@
+package selenium
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/draw"
+	"image/png"
+	"time"
+)
+
+/* Time given to the page to repaint after scrolling, before a tile is captured. */
+var ScrollSettleTime = 150 * time.Millisecond
+
+const pageMetricsScript = `
+var d = document.documentElement, b = document.body || d;
+return {
+	width: Math.max(d.scrollWidth, b.scrollWidth),
+	height: Math.max(d.scrollHeight, b.scrollHeight),
+	viewWidth: d.clientWidth || window.innerWidth,
+	viewHeight: d.clientHeight || window.innerHeight,
+	x: window.pageXOffset,
+	y: window.pageYOffset,
+	ratio: window.devicePixelRatio || 1
+};`
+
+const scrollScript = `
+window.scrollTo(arguments[0], arguments[1]);
+return [window.pageXOffset, window.pageYOffset];`
+
+/* Hide fixed and sticky elements (headers, cookie banners...) so that they show up in the
+first tile only, unhide restores them. */
+const hideFixedScript = `
+var all = document.body ? document.body.getElementsByTagName('*') : [];
+for (var i = 0; i < all.length; i++) {
+	var p = window.getComputedStyle(all[i]).position;
+	if ((p === 'fixed' || p === 'sticky') && !all[i].hasAttribute('data-se-hidden')) {
+		all[i].setAttribute('data-se-hidden', all[i].style.visibility);
+		all[i].style.visibility = 'hidden';
+	}
+}`
+
+const unhideFixedScript = `
+var all = document.querySelectorAll('[data-se-hidden]');
+for (var i = 0; i < all.length; i++) {
+	all[i].style.visibility = all[i].getAttribute('data-se-hidden');
+	all[i].removeAttribute('data-se-hidden');
+}`
+
+type pageMetrics struct {
+	width, height, viewWidth, viewHeight, x, y int
+	ratio                                      float64
+}
+
+func (wd *remoteWD) FullScreenshotPNG() ([]byte, error) {
+	if data, ok := wd.nativeFullScreenshot(); ok {
+		return data, nil
+	}
+
+	img, err := wd.stitchedScreenshot()
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(img)
+}
+
+func (wd *remoteWD) FullScreenshotImage() (image.Image, error) {
+	if data, ok := wd.nativeFullScreenshot(); ok {
+		return png.Decode(bytes.NewReader(data))
+	}
+
+	return wd.stitchedScreenshot()
+}
+
+/* Firefox (geckodriver) renders the whole document itself. */
+func (wd *remoteWD) nativeFullScreenshot() ([]byte, bool) {
+	wd.mu.Lock()
+	unsupported := wd.noFullScreenshot
+	wd.mu.Unlock()
+	if unsupported || wd.capabilities["browserName"] != "firefox" {
+		return nil, false
+	}
+
+	data, err := wd.stringCommand("/session/%s/moz/screenshot/full")
+	if err != nil {
+		wd.debugLog("native full page screenshot failed, stitching instead: %s", err)
+		wd.mu.Lock()
+		wd.noFullScreenshot = true
+		wd.mu.Unlock()
+		return nil, false
+	}
+	d, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, false
+	}
+	return d, true
+}
+
+func (wd *remoteWD) pageMetrics() (*pageMetrics, error) {
+	v, err := wd.ExecuteScript(pageMetricsScript, nil)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := v.(map[string]interface{})
+	number := func(key string) float64 {
+		n, _ := m[key].(float64)
+		return n
+	}
+	metrics := &pageMetrics{
+		width:      int(number("width")),
+		height:     int(number("height")),
+		viewWidth:  int(number("viewWidth")),
+		viewHeight: int(number("viewHeight")),
+		x:          int(number("x")),
+		y:          int(number("y")),
+		ratio:      number("ratio"),
+	}
+	if metrics.viewWidth <= 0 || metrics.viewHeight <= 0 {
+		return nil, &QueryError{Status: 13, Message: "unable to read the viewport size"}
+	}
+	return metrics, nil
+}
+
+func (wd *remoteWD) scrollTo(x, y int) (int, int, error) {
+	v, err := wd.ExecuteScript(scrollScript, []interface{}{x, y})
+	if err != nil {
+		return 0, 0, err
+	}
+	offsets, _ := v.([]interface{})
+	if len(offsets) != 2 {
+		return x, y, nil
+	}
+	ax, _ := offsets[0].(float64)
+	ay, _ := offsets[1].(float64)
+	return int(ax), int(ay), nil
+}
+
+/* Scroll through the document one viewport at a time and stitch the viewport screenshots. */
+func (wd *remoteWD) stitchedScreenshot() (image.Image, error) {
+	m, err := wd.pageMetrics()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		wd.ExecuteScript(unhideFixedScript, nil)
+		wd.scrollTo(m.x, m.y)
+	}()
+
+	var canvas *image.RGBA
+	ratio := m.ratio
+	/* Part of a tile showing the document, without scrollbars. */
+	view := image.Rect(0, 0, scale(m.viewWidth, ratio), scale(m.viewHeight, ratio))
+	for y := 0; y < m.height; y += m.viewHeight {
+		for x := 0; x < m.width; x += m.viewWidth {
+			ax, ay, err := wd.scrollTo(x, y)
+			if err != nil {
+				return nil, err
+			}
+			time.Sleep(ScrollSettleTime)
+
+			tile, err := wd.ScreenshotImage()
+			if err != nil {
+				return nil, err
+			}
+
+			if canvas == nil {
+				canvas = image.NewRGBA(image.Rect(0, 0, scale(m.width, ratio), scale(m.height, ratio)))
+				if _, err = wd.ExecuteScript(hideFixedScript, nil); err != nil {
+					return nil, err
+				}
+			}
+
+			at := image.Pt(scale(ax, ratio), scale(ay, ratio))
+			draw.Draw(canvas, view.Add(at), tile, tile.Bounds().Min, draw.Src)
+		}
+	}
+
+	if canvas == nil {
+		return wd.ScreenshotImage()
+	}
+	return canvas, nil
+}
+
+func scale(v int, ratio float64) int {
+	return int(float64(v)*ratio + 0.5)
+}
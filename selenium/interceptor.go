@@ -0,0 +1,113 @@
+package selenium
+
+import (
+	"encoding/json"
+	"gtf/drivers/log"
+)
+
+/* Wire command as seen by interceptors. */
+type Command struct {
+	Method string
+	URL    string
+	Body   []byte    // JSON parameters, nil for GET and DELETE.
+	Driver WebDriver // Driver sending the command.
+	Secret bool      // Body must not be logged or traced, e.g. typed passwords.
+
+	wd *remoteWD
+}
+
+/* Server reply, StatusCode and Status are those of the HTTP response. */
+type Response struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+/* Sends a command: the next interceptor of the chain, or the transport at its end. */
+type CommandHandler func(cmd *Command) (*Response, error)
+
+/* Wraps every wire command of a driver. It may change the command, call next zero or more
+times, and inspect or replace the reply. The response is nil if the server did not reply,
+it is set for error replies. Interceptors run outside the command lock of the driver and may
+send commands themselves, these go through the whole chain again. */
+type Interceptor func(cmd *Command, next CommandHandler) (*Response, error)
+
+/* Interceptors of drivers created from now on, see WebDriver.SetInterceptors. */
+var DefaultInterceptors = []Interceptor{LogCommands, StoreScreenshots}
+
+func (wd *remoteWD) Use(interceptors ...Interceptor) {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	wd.interceptors = append(wd.interceptors, interceptors...)
+}
+
+func (wd *remoteWD) SetInterceptors(interceptors ...Interceptor) {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	wd.interceptors = append([]Interceptor(nil), interceptors...)
+}
+
+/* Handler running the interceptors in order around the transport. */
+func (wd *remoteWD) chain() CommandHandler {
+	wd.mu.Lock()
+	interceptors := wd.interceptors
+	wd.mu.Unlock()
+
+	handler := CommandHandler(wd.transport)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(cmd *Command) (*Response, error) {
+			return interceptor(cmd, next)
+		}
+	}
+	return handler
+}
+
+/* Log commands and replies, unless debugging is turned off with SetDebug. */
+func LogCommands(cmd *Command, next CommandHandler) (*Response, error) {
+	if cmd.wd == nil || !cmd.wd.debugging() {
+		return next(cmd)
+	}
+
+	cmd.wd.debugLog("-> %s, %s", cmd.Method, cmd.URL)
+	if cmd.Secret {
+		log.ToggleText("Application json", redacted, "off")
+	} else {
+		log.ToggleText("Application json", string(cmd.Body), "off")
+	}
+	res, err := next(cmd)
+
+	status := "no reply"
+	if res != nil {
+		status = res.Status
+	}
+	switch {
+	case err == nil:
+		cmd.wd.debugLog("<- %s, %s\n", status, errors_[SUCCESS])
+	case cmd.wd.Node() != "":
+		cmd.wd.warningLog("<--- %s, %s (node %s)\n", status, err, cmd.wd.Node())
+	default:
+		cmd.wd.warningLog("<--- %s, %s\n", status, err)
+	}
+	return res, err
+}
+
+/* Store the screenshots servers attach to error replies with the driver's screenshot sink. */
+func StoreScreenshots(cmd *Command, next CommandHandler) (*Response, error) {
+	res, err := next(cmd)
+	if res == nil || cmd.wd == nil {
+		return res, err
+	}
+
+	reply := new(serverReply)
+	v := new(value)
+	if json.Unmarshal(res.Body, reply) != nil || json.Unmarshal(reply.Value, v) != nil || v.Screen == nil {
+		return res, err
+	}
+	var s string
+	if e := json.Unmarshal(v.Screen, &s); e != nil {
+		log.Infof("Unmarshal reply.Value.Screen failed: %s", e)
+	}
+	logScreenShot(cmd.wd.screenshotSink(), &s)
+	return res, err
+}
@@ -0,0 +1,80 @@
+package selenium
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/* Log types */
+const (
+	LogBrowser     = "browser"
+	LogDriver      = "driver"
+	LogPerformance = "performance"
+	LogClient      = "client"
+	LogServer      = "server"
+)
+
+/* Log levels, in order of severity. */
+const (
+	LevelSevere  = "SEVERE"
+	LevelWarning = "WARNING"
+	LevelInfo    = "INFO"
+	LevelConfig  = "CONFIG"
+	LevelFine    = "FINE"
+	LevelFiner   = "FINER"
+	LevelFinest  = "FINEST"
+	LevelAll     = "ALL"
+	LevelOff     = "OFF"
+)
+
+/* Log entry */
+type LogEntry struct {
+	Level     string
+	Timestamp time.Time
+	Message   string
+}
+
+type logEntry struct {
+	Level     string  `json:"level"`
+	Timestamp float64 `json:"timestamp"` // Milliseconds since the epoch.
+	Message   string  `json:"message"`
+}
+
+type logReply struct {
+	Value  []logEntry
+	Status int
+}
+
+func (wd *remoteWD) LogTypes() ([]string, error) {
+	return wd.stringsCommand("/session/%s/log/types")
+}
+
+func (wd *remoteWD) Log(typ string) ([]LogEntry, error) {
+	data, err := json.Marshal(map[string]string{"type": typ})
+	if err != nil {
+		return nil, err
+	}
+
+	url := wd.requestURL("/session/%s/log", wd.SessionId())
+	response, err := wd.execute("POST", url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := new(logReply)
+	if err = json.Unmarshal(response, reply); err != nil {
+		return nil, fmt.Errorf(`{"message":"%s"}`, err.Error())
+	}
+
+	entries := make([]LogEntry, len(reply.Value))
+	for i, e := range reply.Value {
+		ms := int64(e.Timestamp)
+		entries[i] = LogEntry{
+			Level:     e.Level,
+			Timestamp: time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)),
+			Message:   e.Message,
+		}
+	}
+	return entries, nil
+}
@@ -9,13 +9,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"gtf/drivers/log"
 	"io/ioutil"
 	"net/http"
-	// "net/http/httputil"
 	"net/url"
-	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 /* Errors returned by Selenium server. */
@@ -49,9 +48,33 @@ const (
 	MAX_REDIRECTS    = 10
 )
 
+/* A remoteWD and its elements may be used from several goroutines. Commands are serialized
+per session: each one holds the command lock for its whole round trip, so e.g. a background
+screenshot runs before or after a click, never in between. Operations made of several
+commands (full page screenshots, storage fallbacks, element screenshots) are not atomic.
+Quit must not run concurrently with other commands of the same session. Interceptors, and so
+screenshot sink callbacks, run outside the lock and may use the driver. */
 type remoteWD struct {
-	id, executor string
+	executor     string
 	capabilities Capabilities
+	client       *http.Client // nil means httpClient.
+
+	command sync.Mutex // Held by transport for the duration of a request and its retries.
+
+	mu             sync.Mutex   // Guards the fields below.
+	id             string       // Session id, see SessionId. Empty once quit.
+	sessionCaps    Capabilities // Capabilities returned by the server for the new session.
+	debug          bool
+	local, session *remoteStorage // Lazily created by LocalStorage and SessionStorage.
+	sink           *ScreenshotSink
+	bidi           *BiDi
+	node           string // Grid node running the session, see SetNode.
+	interceptors   []Interceptor
+	retry          RetryPolicy
+	failures       int  // Consecutive commands that failed in transport.
+	broken         bool // Set once failures reached retry.BreakerThreshold.
+	// Set once the server rejected the native full page screenshot command.
+	noFullScreenshot bool
 	// FIXME
 	// profile             BrowserProfile
 }
@@ -148,7 +171,8 @@ func isRedirect(response *http.Response) bool {
 	return false
 }
 
-func normalizeURL(n string, base string) (string, error) {
+/* Resolve n (absolute, or relative such as "/status.html") against the base URL. */
+func NormalizeURL(n string, base string) (string, error) {
 	baseURL, err := url.Parse(base)
 	if err != nil {
 		return "", fmt.Errorf(
@@ -166,12 +190,50 @@ func (wd *remoteWD) requestURL(template string, args ...interface{}) string {
 	return wd.executor + path
 }
 
-var reg = regexp.MustCompile(`: {\\"method\\":.+?"screen":.+?}`)
-
+/* Send a command through the interceptors of the driver. */
 func (wd *remoteWD) execute(method, url string, data []byte) ([]byte, error) {
-	// Trace := false
-	debugLog("-> %s, %s", method, url)
-	log.ToggleText("Application json", string(data), "off")
+	return wd.run(&Command{Method: method, URL: url, Body: data})
+}
+
+func (wd *remoteWD) run(cmd *Command) ([]byte, error) {
+	cmd.Driver, cmd.wd = wd, wd
+	res, err := wd.chain()(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+/* Innermost handler of the chain: send the command, retried according to the retry policy
+of the driver. */
+func (wd *remoteWD) transport(cmd *Command) (*Response, error) {
+	wd.command.Lock()
+	defer wd.command.Unlock()
+
+	if wd.dead() && !wd.endsSession(cmd) {
+		return nil, ErrSessionDead
+	}
+	policy := wd.retryPolicy()
+	for attempt := 1; ; attempt++ {
+		res, err := wd.send(cmd.Method, cmd.URL, cmd.Body)
+		t, transient := err.(*transientError)
+		if !transient {
+			wd.commandDone(true)
+			return res, err
+		}
+		if attempt >= policy.Attempts || !policy.retryable(cmd.Method, cmd.URL, t) {
+			wd.commandDone(false)
+			return res, t.err
+		}
+		delay := policy.delay(attempt)
+		wd.warningLog("%s %s failed: %s, retry %d/%d in %s", cmd.Method, cmd.URL, t.err, attempt, policy.Attempts-1, delay)
+		time.Sleep(delay)
+	}
+}
+
+/* Send a command once, transport failures are returned as *transientError. The response is
+nil if the server did not reply. */
+func (wd *remoteWD) send(method, url string, data []byte) (*Response, error) {
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(data))
 	if err != nil {
 		return nil, err
@@ -181,68 +243,61 @@ func (wd *remoteWD) execute(method, url string, data []byte) ([]byte, error) {
 		req.Header.Add("Content-Type", JSON_MIME_TYPE)
 	}
 
-	// if Trace {
-	// 	if dump, err := httputil.DumpRequest(req, true); err == nil && log != nil {
-	// 		log.Printf("-> TRACE\n%s", dump)
-	// 	}
-	// }
-
-	res, err := httpClient.Do(req)
+	client := wd.client
+	if client == nil {
+		client = httpClient
+	}
+	res, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, &transientError{err: err, unsent: isDialError(err)}
 	}
-
-	// if Trace {
-	// 	if dump, err := httputil.DumpResponse(res, true); err == nil && log != nil {
-	// 		log.Printf("<- TRACE\n%s", dump)
-	// 	}
-	// }
+	defer res.Body.Close()
 
 	buf, err := ioutil.ReadAll(res.Body)
-	// debugLog("<- %s, %s", res.Status, res.Header["Content-Type"])
-	// log.ToggleText("Application json", string(reg.ReplaceAll(buf, nil)), "off")
+	response := &Response{StatusCode: res.StatusCode, Status: res.Status, Body: buf}
 	if err != nil {
 		buf = []byte(res.Status)
-		return nil, errors.New(string(buf))
+		return response, &transientError{err: errors.New(string(buf))}
+	}
+	if gatewayError(res.StatusCode) {
+		return response, &transientError{err: fmt.Errorf(`{"message":"%s"}`, res.Status), unsent: res.StatusCode == http.StatusServiceUnavailable}
 	}
 
 	reply := new(serverReply)
 	err = json.Unmarshal(buf, reply)
 	if err != nil {
-		return nil, fmt.Errorf(`{"message":"%s"}`, err)
-	}
-
-	v := new(value)
-	if err = json.Unmarshal(reply.Value, v); err == nil && v.Screen != nil {
-		var s string
-		if err = json.Unmarshal(v.Screen, &s); err != nil {
-			log.Infof("Unmarshal reply.Value.Screen failed: %s", err)
+		/* A server failing mid reply, not a proxy's error page for a bad request. */
+		if res.StatusCode >= 500 {
+			return response, &transientError{err: fmt.Errorf(`{"message":"%s"}`, err)}
 		}
-		logScreenShot(&s)
+		if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusMethodNotAllowed {
+			return response, &QueryError{Message: res.Status, Node: wd.Node(), HTTPStatus: res.StatusCode}
+		}
+		return response, fmt.Errorf(`{"message":"%s"}`, err)
 	}
 
 	cleanNils(buf)
-	state, ok := errors_[reply.Status]
-	if !ok {
-		state = fmt.Sprintf("unknown error - %d", reply.Status)
-	}
 	if res.StatusCode >= 400 {
-		warningLog("<--- %s, %s\n", res.Status, state)
-		return nil, &QueryError{Status: reply.Status, Message: state}
-	} else {
-		debugLog("<- %s, %s\n", res.Status, state)
+		return response, wd.httpError(res.StatusCode, reply)
 	}
 
 	if isMimeType(res, JSON_MIME_TYPE) {
 		if reply.Status != SUCCESS {
-			message, ok := errors_[reply.Status]
-			if !ok {
-				message = fmt.Sprintf("unknown error - %d", reply.Status)
-			}
-			return nil, fmt.Errorf(`{"status":%d, "message":"%s"}`, reply.Status, message)
+			return response, wd.statusError(reply.Status)
 		}
 	}
-	return buf, nil
+	return response, nil
+}
+
+/* Client with the package defaults, executor must not be empty. Drivers also trace to the
+file named by SE_TRACE. */
+func newRemoteWD(executor string, capabilities Capabilities) *remoteWD {
+	wd := &remoteWD{executor: executor, capabilities: capabilities, debug: DefaultDebug, retry: DefaultRetryPolicy,
+		interceptors: append([]Interceptor(nil), DefaultInterceptors...)}
+	if trace := traceFromEnv(); trace != nil {
+		wd.interceptors = append(wd.interceptors, trace)
+	}
+	return wd
 }
 
 /* Create new remote client, this will also start a new session.
@@ -252,11 +307,18 @@ func (wd *remoteWD) execute(method, url string, data []byte) ([]byte, error) {
    Empty string means DEFAULT_EXECUTOR
 */
 func NewRemote(capabilities Capabilities, executor string) (WebDriver, error) {
+	return NewRemoteClient(capabilities, executor, nil)
+}
+
+/* Like NewRemote, commands are sent with client, e.g. one made by HTTPClient with a recording
+transport. nil means the shared client returned by GetHTTPClient. */
+func NewRemoteClient(capabilities Capabilities, executor string, client *http.Client) (WebDriver, error) {
 	if len(executor) == 0 {
 		executor = DEFAULT_EXECUTOR
 	}
 
-	wd := &remoteWD{executor: executor, capabilities: capabilities}
+	wd := newRemoteWD(executor, capabilities)
+	wd.client = client
 	// FIXME: Handle profile
 
 	_, err := wd.NewSession()
@@ -267,8 +329,32 @@ func NewRemote(capabilities Capabilities, executor string) (WebDriver, error) {
 	return wd, nil
 }
 
+/* Create a client for the running session sessionId, e.g. one kept open by a failed test.
+The capabilities are refreshed from the server, W3C servers don't report them for an
+existing session, the session is only checked to be alive then.
+
+   Empty executor means DEFAULT_EXECUTOR
+*/
+func Attach(executor, sessionId string) (WebDriver, error) {
+	if len(executor) == 0 {
+		executor = DEFAULT_EXECUTOR
+	}
+
+	wd := newRemoteWD(executor, Capabilities{})
+	wd.id = sessionId
+	caps, err := wd.Capabilities()
+	if err == nil {
+		wd.capabilities, wd.sessionCaps = caps, caps
+		return wd, nil
+	}
+	if _, err := wd.CurrentURL(); err != nil {
+		return nil, fmt.Errorf(`{"message":"session %s not available: %s"}`, sessionId, err)
+	}
+	return wd, nil
+}
+
 func (wd *remoteWD) stringCommand(urlTemplate string) (string, error) {
-	url := wd.requestURL(urlTemplate, wd.id)
+	url := wd.requestURL(urlTemplate, wd.SessionId())
 	response, err := wd.execute("GET", url, nil)
 	if err != nil {
 		return "", err
@@ -280,11 +366,7 @@ func (wd *remoteWD) stringCommand(urlTemplate string) (string, error) {
 		return "", fmt.Errorf(`{"message":"%s"}`, err.Error())
 	}
 	if reply.Status != SUCCESS {
-		message, ok := errors_[reply.Status]
-		if !ok {
-			message = fmt.Sprintf("unknown error - %d", reply.Status)
-		}
-		return "", fmt.Errorf(`{"status":%d, "message":"%s"}`, reply.Status, message)
+		return "", wd.statusError(reply.Status)
 	}
 
 	if reply.Value == nil {
@@ -300,14 +382,14 @@ func (wd *remoteWD) voidCommand(urlTemplate string, params interface{}) (err err
 		data, err = json.Marshal(params)
 	}
 	if err == nil {
-		_, err = wd.execute("POST", wd.requestURL(urlTemplate, wd.id), data)
+		_, err = wd.execute("POST", wd.requestURL(urlTemplate, wd.SessionId()), data)
 	}
 	return
 
 }
 
 func (wd *remoteWD) stringsCommand(urlTemplate string) ([]string, error) {
-	url := wd.requestURL(urlTemplate, wd.id)
+	url := wd.requestURL(urlTemplate, wd.SessionId())
 	response, err := wd.execute("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -319,18 +401,14 @@ func (wd *remoteWD) stringsCommand(urlTemplate string) ([]string, error) {
 	}
 
 	if reply.Status != SUCCESS {
-		message, ok := errors_[reply.Status]
-		if !ok {
-			message = fmt.Sprintf("unknown error - %d", reply.Status)
-		}
-		return nil, fmt.Errorf(`{"status":%d, "message":"%s"}`, reply.Status, message)
+		return nil, wd.statusError(reply.Status)
 	}
 
 	return reply.Value, nil
 }
 
 func (wd *remoteWD) boolCommand(urlTemplate string) (bool, error) {
-	url := wd.requestURL(urlTemplate, wd.id)
+	url := wd.requestURL(urlTemplate, wd.SessionId())
 	response, err := wd.execute("GET", url, nil)
 	if err != nil {
 		return false, fmt.Errorf(`{"message":"%s"}`, err.Error())
@@ -342,11 +420,9 @@ func (wd *remoteWD) boolCommand(urlTemplate string) (bool, error) {
 		return false, fmt.Errorf(`{"message":"%s"}`, err.Error())
 	}
 
-	message, ok := errors_[reply.Status]
-	if !ok {
-		message = fmt.Sprintf("unknown error - %d", reply.Status)
+	if reply.Status != SUCCESS {
+		return false, wd.statusError(reply.Status)
 	}
-	return false, fmt.Errorf(`{"status":%d, "message":"%s"}`, reply.Status, message)
 
 	return reply.Value, nil
 }
@@ -367,11 +443,7 @@ func (wd *remoteWD) Status() (*Status, error) {
 	}
 
 	if status.Status != SUCCESS {
-		message, ok := errors_[status.Status]
-		if !ok {
-			message = fmt.Sprintf("unknown error - %d", status.Status)
-		}
-		return nil, fmt.Errorf(`{"status":%d, "message":"%s"}`, status.Status, message)
+		return nil, wd.statusError(status.Status)
 	}
 
 	return &status.Value, nil
@@ -382,6 +454,11 @@ func (wd *remoteWD) NewSession() (string, error) {
 		"sessionId":           nil,
 		"desiredCapabilities": wd.capabilities,
 	}
+	/* Servers seeing W3C capabilities start a W3C session, while the commands of this client
+	are JSON wire. Only BiDi, which is W3C only, asks for it. */
+	if bidi, _ := wd.capabilities["webSocketUrl"].(bool); bidi {
+		message["capabilities"] = map[string]interface{}{"alwaysMatch": w3cCapabilities(wd.capabilities)}
+	}
 	data, err := json.Marshal(message)
 	if err != nil {
 		return "", nil
@@ -396,17 +473,68 @@ func (wd *remoteWD) NewSession() (string, error) {
 	reply := new(serverReply)
 	json.Unmarshal(response, reply)
 
-	wd.id = *reply.SessionId
-
-	return wd.id, nil
+	/* JSON wire servers reply the session id next to the capabilities, W3C servers wrap
+	both in value. */
+	w3c := new(struct {
+		SessionId    string
+		Capabilities Capabilities
+	})
+	json.Unmarshal(reply.Value, w3c)
+	var id string
+	caps := Capabilities{}
+	switch {
+	case reply.SessionId != nil:
+		id = *reply.SessionId
+		json.Unmarshal(reply.Value, &caps)
+	case w3c.SessionId != "":
+		id, caps = w3c.SessionId, w3c.Capabilities
+	default:
+		return "", fmt.Errorf(`{"message":"no session id in new session reply"}`)
+	}
+
+	wd.mu.Lock()
+	wd.id, wd.sessionCaps = id, caps
+	wd.mu.Unlock()
+	return id, nil
+}
+
+/* Standard W3C capabilities plus the vendor ("goog:", "moz:"...) ones, W3C servers reject
+the legacy JSON wire keys. */
+func w3cCapabilities(caps Capabilities) Capabilities {
+	standard := map[string]bool{
+		"browserName": true, "browserVersion": true, "platformName": true, "acceptInsecureCerts": true,
+		"pageLoadStrategy": true, "proxy": true, "setWindowRect": true, "timeouts": true,
+		"strictFileInteractability": true, "unhandledPromptBehavior": true, "webSocketUrl": true,
+	}
+	w3c := Capabilities{}
+	for k, v := range caps {
+		if standard[k] || strings.Contains(k, ":") {
+			w3c[k] = v
+		}
+	}
+	return w3c
 }
 
 func (wd *remoteWD) SessionId() string {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
 	return wd.id
 }
 
+func (wd *remoteWD) SetNode(node string) {
+	wd.mu.Lock()
+	wd.node = node
+	wd.mu.Unlock()
+}
+
+func (wd *remoteWD) Node() string {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	return wd.node
+}
+
 func (wd *remoteWD) Capabilities() (Capabilities, error) {
-	url := wd.requestURL("/session/%s", wd.id)
+	url := wd.requestURL("/session/%s", wd.SessionId())
 	response, err := wd.execute("GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf(`{"message":"%s"}`, err.Error())
@@ -419,11 +547,7 @@ func (wd *remoteWD) Capabilities() (Capabilities, error) {
 	}
 
 	if c.Status != SUCCESS {
-		message, ok := errors_[c.Status]
-		if !ok {
-			message = fmt.Sprintf("unknown error - %d", c.Status)
-		}
-		return nil, fmt.Errorf(`{"status":%d, "message":"%s"}`, c.Status, message)
+		return nil, wd.statusError(c.Status)
 	}
 
 	return c.Value, nil
@@ -468,11 +592,18 @@ func (wd *remoteWD) ActivateEngine(engine string) error {
 }
 
 func (wd *remoteWD) Quit() error {
-	url := wd.requestURL("/session/%s", wd.id)
+	url := wd.requestURL("/session/%s", wd.SessionId())
 	_, err := wd.execute("DELETE", url, nil)
+	wd.mu.Lock()
 	if err == nil {
 		wd.id = ""
 	}
+	b := wd.bidi
+	wd.bidi = nil
+	wd.mu.Unlock()
+	if b != nil {
+		b.Close()
+	}
 
 	return err
 }
@@ -486,7 +617,7 @@ func (wd *remoteWD) WindowHandles() ([]string, error) {
 }
 
 func (wd *remoteWD) CurrentURL() (string, error) {
-	url := wd.requestURL("/session/%s/url", wd.id)
+	url := wd.requestURL("/session/%s/url", wd.SessionId())
 	response, err := wd.execute("GET", url, nil)
 	if err != nil {
 		return "", fmt.Errorf(`{"message":"%s"}`, err.Error())
@@ -499,7 +630,7 @@ func (wd *remoteWD) CurrentURL() (string, error) {
 }
 
 func (wd *remoteWD) Get(url string) error {
-	requestURL := wd.requestURL("/session/%s/url", wd.id)
+	requestURL := wd.requestURL("/session/%s/url", wd.SessionId())
 	params := map[string]string{
 		"url": url,
 	}
@@ -551,7 +682,7 @@ func (wd *remoteWD) find(by, value, suffix, url string) ([]byte, error) {
 	}
 
 	urlTemplate := url + suffix
-	url = wd.requestURL(urlTemplate, wd.id)
+	url = wd.requestURL(urlTemplate, wd.SessionId())
 	return wd.execute("POST", url, data)
 }
 
@@ -563,11 +694,7 @@ func (wd *remoteWD) DecodeElement(data []byte) (WebElement, error) {
 	}
 
 	if reply.Status != SUCCESS {
-		message, ok := errors_[reply.Status]
-		if !ok {
-			message = fmt.Sprintf("unknown error - %d", reply.Status)
-		}
-		return nil, fmt.Errorf(`{"status":%d, "message":"%s"}`, reply.Status, message)
+		return nil, wd.statusError(reply.Status)
 	}
 
 	elem := &remoteWE{wd, reply.Value.ELEMENT}
@@ -608,7 +735,7 @@ func (wd *remoteWD) FindElements(by, value string) ([]WebElement, error) {
 }
 
 func (wd *remoteWD) Close() error {
-	url := wd.requestURL("/session/%s/window", wd.id)
+	url := wd.requestURL("/session/%s/window", wd.SessionId())
 	_, err := wd.execute("DELETE", url, nil)
 	return err
 }
@@ -619,7 +746,7 @@ func (wd *remoteWD) SwitchWindow(name string) error {
 }
 
 func (wd *remoteWD) CloseWindow(name string) error {
-	url := wd.requestURL("/session/%s/window", wd.id)
+	url := wd.requestURL("/session/%s/window", wd.SessionId())
 	_, err := wd.execute("DELETE", url, nil)
 	return err
 }
@@ -633,7 +760,7 @@ func (wd *remoteWD) MaximizeWindow(name string) error {
 		}
 	}
 
-	url := wd.requestURL("/session/%s/window/%s/maximize", wd.id, name)
+	url := wd.requestURL("/session/%s/window/%s/maximize", wd.SessionId(), name)
 	_, err = wd.execute("POST", url, nil)
 	return err
 }
@@ -644,7 +771,7 @@ func (wd *remoteWD) SwitchFrame(frame string) error {
 }
 
 func (wd *remoteWD) ActiveElement() (WebElement, error) {
-	url := wd.requestURL("/session/%s/element/active", wd.id)
+	url := wd.requestURL("/session/%s/element/active", wd.SessionId())
 	response, err := wd.execute("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -654,7 +781,7 @@ func (wd *remoteWD) ActiveElement() (WebElement, error) {
 }
 
 func (wd *remoteWD) GetCookies() ([]Cookie, error) {
-	url := wd.requestURL("/session/%s/cookie", wd.id)
+	url := wd.requestURL("/session/%s/cookie", wd.SessionId())
 	data, err := wd.execute("GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf(`{"message":"%s"}`, err.Error())
@@ -675,13 +802,13 @@ func (wd *remoteWD) AddCookie(cookie *Cookie) error {
 }
 
 func (wd *remoteWD) DeleteAllCookies() error {
-	url := wd.requestURL("/session/%s/cookie", wd.id)
+	url := wd.requestURL("/session/%s/cookie", wd.SessionId())
 	_, err := wd.execute("DELETE", url, nil)
 	return err
 }
 
 func (wd *remoteWD) DeleteCookie(name string) error {
-	url := wd.requestURL("/session/%s/cookie/%s", wd.id, name)
+	url := wd.requestURL("/session/%s/cookie/%s", wd.SessionId(), name)
 	_, err := wd.execute("DELETE", url, nil)
 	return err
 }
@@ -737,7 +864,7 @@ func (wd *remoteWD) execScriptRaw(script string, args []interface{}, suffix stri
 	}
 
 	template := "/session/%s/execute" + suffix
-	url := wd.requestURL(template, wd.id)
+	url := wd.requestURL(template, wd.SessionId())
 	return wd.execute("POST", url, data)
 }
 
@@ -778,7 +905,7 @@ func (wd *remoteWD) Screenshot() (string, error) {
 		return "", err
 	}
 
-	return logScreenShot(&data)
+	return logScreenShot(wd.screenshotSink(), &data)
 }
 
 // WebElement interface implementation
@@ -794,13 +921,27 @@ func (elem *remoteWE) Click() error {
 }
 
 func (elem *remoteWE) SendKeys(keys string) error {
+	return elem.sendKeys(keys, false)
+}
+
+/* Like SendKeys, the keys are left out of logs and traces. */
+func (elem *remoteWE) SendSecretKeys(keys string) error {
+	return elem.sendKeys(keys, true)
+}
+
+func (elem *remoteWE) sendKeys(keys string, secret bool) error {
 	chars := make([]string, len(keys))
 	for i, c := range keys {
 		chars[i] = string(c)
 	}
-	params := map[string][]string{"value": chars}
-	urlTemplate := fmt.Sprintf("/session/%%s/element/%s/value", elem.id)
-	return elem.parent.voidCommand(urlTemplate, params)
+	data, err := json.Marshal(map[string][]string{"value": chars})
+	if err != nil {
+		return err
+	}
+	wd := elem.parent
+	url := wd.requestURL(fmt.Sprintf("/session/%%s/element/%s/value", elem.id), wd.SessionId())
+	_, err = wd.run(&Command{Method: "POST", URL: url, Body: data, Secret: secret})
+	return err
 }
 
 func (elem *remoteWE) TagName() (string, error) {
@@ -876,7 +1017,7 @@ func (elem *remoteWE) GetAttribute(name string) (string, error) {
 func (elem *remoteWE) location(suffix string) (*Point, error) {
 	wd := elem.parent
 	path := "/session/%s/element/%s/location" + suffix
-	url := wd.requestURL(path, wd.id, elem.id)
+	url := wd.requestURL(path, wd.SessionId(), elem.id)
 	response, err := wd.execute("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -900,7 +1041,7 @@ func (elem *remoteWE) LocationInView() (*Point, error) {
 
 func (elem *remoteWE) Size() (*Size, error) {
 	wd := elem.parent
-	url := wd.requestURL("/session/%s/element/%s/size", wd.id, elem.id)
+	url := wd.requestURL("/session/%s/element/%s/size", wd.SessionId(), elem.id)
 	response, err := wd.execute("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -916,12 +1057,19 @@ func (elem *remoteWE) Size() (*Size, error) {
 
 func (elem *remoteWE) CSSProperty(name string) (string, error) {
 	wd := elem.parent
-	urlTemplate := fmt.Sprintf("/session/%s/element/%s/css/%s", wd.id, elem.id, name)
+	urlTemplate := fmt.Sprintf("/session/%s/element/%s/css/%s", wd.SessionId(), elem.id, name)
 	return elem.parent.stringCommand(urlTemplate)
 }
 
 func init() {
-	httpClient = &http.Client{
+	httpClient = HTTPClient(nil)
+}
+
+/* Client suited for the wire protocol sending requests with transport, nil means
+http.DefaultTransport. */
+func HTTPClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport: transport,
 		// WebDriver requires that all requests have an 'Accept: application/json' header. We must add
 		// it here because by default net/http will not include that header when following redirects.
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -929,11 +1077,6 @@ func init() {
 				return fmt.Errorf("too many redirects (%d)", len(via))
 			}
 			req.Header.Add("Accept", JSON_MIME_TYPE)
-			// if Trace {
-			// 	if dump, err := httputil.DumpRequest(req, true); err == nil && Log != nil {
-			// 		Log.Printf("-> TRACE (redirected request)\n%s", dump)
-			// 	}
-			// }
 			return nil
 		},
 	}
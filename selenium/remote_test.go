@@ -0,0 +1,235 @@
+package selenium
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"se/websocket"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/* JSON wire stub serving any number of sessions. It fails the test when two commands of one
+session overlap, commands of a session must be serialized. */
+type stubServer struct {
+	t      *testing.T
+	nextID int32
+
+	mu     sync.Mutex
+	urls   map[string]string // Current url of every session.
+	active map[string]bool   // Sessions with a command in progress.
+	caps   string            // Value of the new session replies.
+	bidi   http.HandlerFunc  // Serves /bidi if set.
+}
+
+func newStub(t *testing.T) (*stubServer, string) {
+	s := &stubServer{t: t, urls: map[string]string{}, active: map[string]bool{}, caps: "{}"}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv.URL
+}
+
+func (s *stubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/bidi" && s.bidi != nil {
+		s.bidi(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", JSON_MIME_TYPE)
+	if r.Method == "POST" && r.URL.Path == "/session" {
+		id := fmt.Sprintf("s%d", atomic.AddInt32(&s.nextID, 1))
+		fmt.Fprintf(w, `{"sessionId":%q,"status":0,"value":%s}`, id, s.caps)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/session/"), "/", 2)
+	id, command := parts[0], ""
+	if len(parts) == 2 {
+		command = parts[1]
+	}
+	s.mu.Lock()
+	if s.active[id] {
+		s.t.Errorf("session %s: %s %s overlaps another command", id, r.Method, command)
+	}
+	s.active[id] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active[id] = false
+		s.mu.Unlock()
+	}()
+	time.Sleep(time.Millisecond) // Give overlapping commands a chance to show up.
+
+	var value interface{}
+	switch {
+	case command == "url" && r.Method == "POST":
+		var body struct{ Url string }
+		json.NewDecoder(r.Body).Decode(&body)
+		s.mu.Lock()
+		s.urls[id] = body.Url
+		s.mu.Unlock()
+	case command == "url":
+		s.mu.Lock()
+		value = s.urls[id]
+		s.mu.Unlock()
+	case command == "element":
+		value = map[string]string{"ELEMENT": id + "-e1"}
+	case command == "title":
+		value = "title of " + id
+	}
+	data, _ := json.Marshal(value)
+	fmt.Fprintf(w, `{"sessionId":%q,"status":0,"value":%s}`, id, data)
+}
+
+func newQuietDriver(executor string) (WebDriver, error) {
+	wd, err := NewRemote(Capabilities{"browserName": "stub"}, executor)
+	if err != nil {
+		return nil, fmt.Errorf("new session: %s", err)
+	}
+	wd.SetDebug(false)
+	return wd, nil
+}
+
+/* newQuietDriver for the test goroutine, t.Fatalf must not be called from other goroutines. */
+func quietDriver(t *testing.T, executor string) WebDriver {
+	t.Helper()
+	wd, err := newQuietDriver(executor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
+
+/* Run with -race: several sessions used in parallel, each one from several goroutines. */
+func TestParallelSessions(t *testing.T) {
+	_, executor := newStub(t)
+
+	var sessions sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		sessions.Add(1)
+		go func(i int) {
+			defer sessions.Done()
+			wd, err := newQuietDriver(executor)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer wd.Quit()
+
+			var commands int32
+			wd.Use(func(cmd *Command, next CommandHandler) (*Response, error) {
+				atomic.AddInt32(&commands, 1)
+				return next(cmd)
+			})
+
+			url := fmt.Sprintf("http://app.local/%d", i)
+			if err := wd.Get(url); err != nil {
+				t.Errorf("get: %s", err)
+				return
+			}
+
+			var workers sync.WaitGroup
+			for j := 0; j < 4; j++ {
+				workers.Add(1)
+				go func(j int) {
+					defer workers.Done()
+					wd.SetDebug(false)
+					wd.SetNode(fmt.Sprintf("node-%d", j))
+					wd.Node()
+					if current, err := wd.CurrentURL(); err != nil || current != url {
+						t.Errorf("session %s: url %q (%v), want %q", wd.SessionId(), current, err, url)
+					}
+					e, err := wd.FindElement("id", "name")
+					if err != nil {
+						t.Errorf("find element: %s", err)
+						return
+					}
+					if err = e.Click(); err != nil {
+						t.Errorf("click: %s", err)
+					}
+					if title, err := wd.Title(); err != nil || title != "title of "+wd.SessionId() {
+						t.Errorf("session %s: title %q (%v)", wd.SessionId(), title, err)
+					}
+				}(j)
+			}
+			workers.Wait()
+
+			if n := atomic.LoadInt32(&commands); n != 1+4*4 {
+				t.Errorf("session %s: interceptor saw %d commands, want %d", wd.SessionId(), n, 1+4*4)
+			}
+		}(i)
+	}
+	sessions.Wait()
+}
+
+/* The BiDi connection is dialed without holding the driver's state lock. */
+func TestBiDiDialDoesNotBlockDriver(t *testing.T) {
+	stub, executor := newStub(t)
+	dialing, release := make(chan struct{}), make(chan struct{})
+	stub.bidi = func(w http.ResponseWriter, r *http.Request) {
+		close(dialing)
+		<-release
+		c, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		for {
+			if _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+	stub.caps = fmt.Sprintf(`{"webSocketUrl":"ws%s/bidi"}`, strings.TrimPrefix(executor, "http"))
+	wd := quietDriver(t, executor)
+
+	connected := make(chan error, 1)
+	go func() {
+		_, err := wd.BiDi()
+		connected <- err
+	}()
+	<-dialing
+
+	done := make(chan struct{})
+	go func() {
+		wd.SetNode("node-1")
+		wd.Node()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("driver state locked while BiDi dials")
+	}
+
+	close(release)
+	if err := <-connected; err != nil {
+		t.Fatalf("bidi: %s", err)
+	}
+	if err := wd.Quit(); err != nil {
+		t.Errorf("quit: %s", err)
+	}
+}
+
+/* Run with -race: the session id may be read (logs, traces) while the session is quit. */
+func TestQuitWhileReadingSessionId(t *testing.T) {
+	_, executor := newStub(t)
+	wd := quietDriver(t, executor)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for wd.SessionId() != "" {
+		}
+	}()
+	if err := wd.Quit(); err != nil {
+		t.Fatalf("quit: %s", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("session id still set after Quit")
+	}
+}
@@ -0,0 +1,132 @@
+package selenium
+
+import (
+	"errors"
+	"gtf/drivers/log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/* Returned by every command but Quit once the circuit breaker marked the session dead. */
+var ErrSessionDead = errors.New("selenium: session marked dead after repeated transport failures")
+
+/* How commands failing in transport (connection errors, 502/503/504 replies, 5xx replies whose
+body is not JSON) are retried. Idempotent commands (GET, finding elements) are retried on any such
+failure, other POSTs only when the request never reached the server (connection refused,
+503 from the hub) unless RetryPOST is set. */
+type RetryPolicy struct {
+	Attempts   int           // Tries per command including the first one, 1 disables retries.
+	Backoff    time.Duration // Delay before the first retry, doubled for every further one.
+	MaxBackoff time.Duration // Upper bound of the delay, 0 means no bound.
+	Jitter     float64       // Delays vary randomly by up to this fraction, e.g. 0.2 for +-20%.
+	RetryPOST  bool          // Also retry non idempotent commands that may have reached the server.
+	// Consecutive failed commands after which the session is considered dead and every
+	// further command but Quit fails with ErrSessionDead, 0 disables the breaker.
+	BreakerThreshold int
+}
+
+/* Policy of drivers created from now on, see WebDriver.SetRetryPolicy. */
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:         3,
+	Backoff:          250 * time.Millisecond,
+	MaxBackoff:       2 * time.Second,
+	Jitter:           0.2,
+	BreakerThreshold: 5,
+}
+
+/* Transport failure that may go away when the command is sent again. */
+type transientError struct {
+	err    error
+	unsent bool // The server did not process the request, resending is always safe.
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (wd *remoteWD) SetRetryPolicy(policy RetryPolicy) {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	wd.retry = policy
+	wd.failures, wd.broken = 0, false
+}
+
+func (wd *remoteWD) retryPolicy() RetryPolicy {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	return wd.retry
+}
+
+func (wd *remoteWD) dead() bool {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	return wd.broken
+}
+
+/* Feed the circuit breaker, ok means the server answered the command. */
+func (wd *remoteWD) commandDone(ok bool) {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	if ok {
+		wd.failures = 0
+		return
+	}
+	wd.failures++
+	if wd.retry.BreakerThreshold > 0 && wd.failures >= wd.retry.BreakerThreshold && !wd.broken {
+		wd.broken = true
+		log.Warningf("Session %s marked dead after %d failed commands\n", wd.id, wd.failures)
+	}
+}
+
+/* Check if cmd is Quit (DELETE /session/:id). It is sent even to dead sessions, otherwise
+they would keep their grid slot. */
+func (wd *remoteWD) endsSession(cmd *Command) bool {
+	path := strings.TrimPrefix(cmd.URL, wd.executor)
+	return cmd.Method == "DELETE" && strings.HasPrefix(path, "/session/") &&
+		!strings.Contains(strings.TrimPrefix(path, "/session/"), "/")
+}
+
+func (p RetryPolicy) retryable(method, url string, err *transientError) bool {
+	return err.unsent || p.RetryPOST || idempotent(method, url)
+}
+
+/* Delay before retry number attempt (1 for the first retry). */
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+/* Commands that can be sent twice without changing the outcome. */
+func idempotent(method, url string) bool {
+	if method == "GET" {
+		return true
+	}
+	return method == "POST" && (strings.HasSuffix(url, "/element") || strings.HasSuffix(url, "/elements"))
+}
+
+/* Replies of proxies and hubs that could not reach the node. */
+func gatewayError(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+/* Check if the connection failed before anything was sent. */
+func isDialError(err error) bool {
+	if u, ok := err.(*url.Error); ok {
+		err = u.Err
+	}
+	op, ok := err.(*net.OpError)
+	return ok && op.Op == "dial"
+}
@@ -0,0 +1,78 @@
+package selenium
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetries = RetryPolicy{Attempts: 3, Backoff: time.Millisecond, BreakerThreshold: 2}
+
+/* The breaker stops commands of a dead session, but Quit still reaches the server. */
+func TestBreakerLetsQuitThrough(t *testing.T) {
+	var quits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/session":
+			fmt.Fprint(w, `{"sessionId":"s1","status":0,"value":{}}`)
+		case r.Method == "DELETE" && r.URL.Path == "/session/s1":
+			atomic.AddInt32(&quits, 1)
+			w.Header().Set("Content-Type", JSON_MIME_TYPE)
+			fmt.Fprint(w, `{"sessionId":"s1","status":0,"value":null}`)
+		default:
+			http.Error(w, "node gone", http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	wd := quietDriver(t, srv.URL)
+	wd.SetRetryPolicy(fastRetries)
+	for i := 0; i < 2; i++ {
+		if _, err := wd.CurrentURL(); err == nil || err == ErrSessionDead {
+			t.Fatalf("command %d: got %v, want a gateway error", i, err)
+		}
+	}
+	if _, err := wd.Title(); err != ErrSessionDead {
+		t.Errorf("got %v, want ErrSessionDead", err)
+	}
+	if err := wd.DeleteCookie("session"); err != ErrSessionDead {
+		t.Errorf("delete cookie: got %v, want ErrSessionDead", err)
+	}
+
+	if err := wd.Quit(); err != nil {
+		t.Errorf("quit: %s", err)
+	}
+	if atomic.LoadInt32(&quits) != 1 {
+		t.Errorf("quit sent %d times, want 1", quits)
+	}
+}
+
+/* Error pages of proxies are final replies: not retried, not counted by the breaker. */
+func TestHTMLErrorPageNotRetried(t *testing.T) {
+	var gets int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/session" {
+			fmt.Fprint(w, `{"sessionId":"s1","status":0,"value":{}}`)
+			return
+		}
+		atomic.AddInt32(&gets, 1)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<html><body>404 Not Found</body></html>")
+	}))
+	defer srv.Close()
+
+	wd := quietDriver(t, srv.URL)
+	wd.SetRetryPolicy(fastRetries)
+	for i := 0; i < 3; i++ {
+		if _, err := wd.CurrentURL(); err == nil || err == ErrSessionDead {
+			t.Fatalf("command %d: got %v, want the error page", i, err)
+		}
+	}
+	if n := atomic.LoadInt32(&gets); n != 3 {
+		t.Errorf("%d requests for 3 commands, want no retries", n)
+	}
+}
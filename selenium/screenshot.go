@@ -0,0 +1,103 @@
+package selenium
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+)
+
+func (wd *remoteWD) SetScreenshotSink(sink *ScreenshotSink) {
+	wd.mu.Lock()
+	wd.sink = sink
+	wd.mu.Unlock()
+}
+
+func (wd *remoteWD) screenshotSink() *ScreenshotSink {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	if wd.sink == nil {
+		return DefaultScreenshotSink
+	}
+	return wd.sink
+}
+
+func (wd *remoteWD) ScreenshotPNG() ([]byte, error) {
+	data, err := wd.stringCommand("/session/%s/screenshot")
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(data)
+}
+
+func (wd *remoteWD) ScreenshotImage() (image.Image, error) {
+	data, err := wd.ScreenshotPNG()
+	if err != nil {
+		return nil, err
+	}
+
+	return png.Decode(bytes.NewReader(data))
+}
+
+/* Device pixel ratio of the current window, 1 if the browser does not report one. */
+func (wd *remoteWD) devicePixelRatio() float64 {
+	v, err := wd.ExecuteScript("return window.devicePixelRatio;", nil)
+	if ratio, ok := v.(float64); err == nil && ok && ratio > 0 {
+		return ratio
+	}
+	return 1
+}
+
+/* Uses the W3C element screenshot endpoint, servers without it get the element cropped out
+of a viewport screenshot. */
+func (elem *remoteWE) Screenshot() ([]byte, error) {
+	urlTemplate := fmt.Sprintf("/session/%%s/element/%s/screenshot", elem.id)
+	data, err := elem.parent.stringCommand(urlTemplate)
+	if err == nil {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if !isUnsupported(err) {
+		return nil, err
+	}
+
+	/* LocationInView scrolls the element into view and reports viewport coordinates. */
+	location, err := elem.LocationInView()
+	if err != nil {
+		return nil, err
+	}
+	size, err := elem.Size()
+	if err != nil {
+		return nil, err
+	}
+	page, err := elem.parent.ScreenshotImage()
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := elem.parent.devicePixelRatio()
+	rect := image.Rect(
+		int(float64(location.X)*ratio),
+		int(float64(location.Y)*ratio),
+		int(float64(location.X+size.Width)*ratio),
+		int(float64(location.Y+size.Height)*ratio))
+	return encodePNG(Crop(page, rect))
+}
+
+/* Copy the part of img within rect, the result's bounds start at (0, 0). */
+func Crop(img image.Image, rect image.Rectangle) image.Image {
+	rect = rect.Intersect(img.Bounds())
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -1,5 +1,9 @@
 package selenium
 
+import (
+	"image"
+)
+
 const (
 	Version = "0.8.1" // Driver version
 )
@@ -108,12 +112,14 @@ type Size struct {
 
 /* Cookie */
 type Cookie struct {
-	Name   string `json:"name"`
-	Value  string `json:"value"`
-	Path   string `json:"path"`
-	Domain string `json:"domain"`
-	Secure bool   `json:"secure"`
-	Expiry uint   `json:"expiry"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path"`
+	Domain   string `json:"domain"`
+	Secure   bool   `json:"secure"`
+	Expiry   uint   `json:"expiry,omitempty"` // Seconds since the epoch, 0 for session cookies.
+	HTTPOnly bool   `json:"httpOnly"`
+	SameSite string `json:"sameSite,omitempty"` // "Strict", "Lax" or "None", empty if the server does not report it.
 }
 
 type WebDriver interface {
@@ -128,6 +134,9 @@ type WebDriver interface {
 
 	/* Current session capabilities */
 	Capabilities() (Capabilities, error)
+	/* WebDriver BiDi connection of the session, needs the "webSocketUrl": true capability.
+	   The connection and its event channels are closed by Quit. */
+	BiDi() (*BiDi, error)
 
 	/* Configure the amount of time a particular type of operation can execute for before it is aborted.
 	   Valid types: "script" for script timeouts, "implicit" for modifying the implicit wait timeout and "page load" for setting a page load timeout. */
@@ -208,6 +217,12 @@ type WebDriver interface {
 	/* Delete a cookie */
 	DeleteCookie(name string) error
 
+	// Web storage
+	/* Local storage of the current origin. */
+	LocalStorage() Storage
+	/* Session storage of the current origin. */
+	SessionStorage() Storage
+
 	// Mouse
 	/* Click mouse button, button should be on of RightButton, MiddleButton or
 	LeftButton.
@@ -225,8 +240,39 @@ type WebDriver interface {
 	modifier can be one of ShiftKey, ControlKey, AltKey, MetaKey.
 	*/
 	SendModifier(modifier string, isDown bool) error
-	/* Take a screenshot */
+	/* Take a screenshot, store it through the screenshot sink and return the file name. */
 	Screenshot() (string, error)
+	/* Take a screenshot, return the PNG data. */
+	ScreenshotPNG() ([]byte, error)
+	/* Take a screenshot, return the decoded image. */
+	ScreenshotImage() (image.Image, error)
+	/* Screenshot of the whole document (not only the viewport), return the PNG data. */
+	FullScreenshotPNG() ([]byte, error)
+	/* Screenshot of the whole document (not only the viewport), return the decoded image. */
+	FullScreenshotImage() (image.Image, error)
+	/* Set where Screenshot stores files, nil means DefaultScreenshotSink. */
+	SetScreenshotSink(sink *ScreenshotSink)
+
+	// Logs
+	/* Available log types, see the Log* constants. */
+	LogTypes() ([]string, error)
+	/* Log entries of type typ collected since the previous call. */
+	Log(typ string) ([]LogEntry, error)
+	/* Record the grid node running the session, it is added to failure logs and status
+	errors. Drivers don't look their node up, see grid.Hub.Locate. */
+	SetNode(node string)
+	/* Grid node running the session, empty if unknown. */
+	Node() string
+	/* Set how commands failing in transport are retried, see DefaultRetryPolicy. Also
+	revives a session the circuit breaker marked dead. */
+	SetRetryPolicy(policy RetryPolicy)
+	/* Add interceptors around every wire command, after (inside) the existing ones. */
+	Use(interceptors ...Interceptor)
+	/* Replace the interceptors, e.g. SetInterceptors() drops the default logging and
+	screenshot storing, see DefaultInterceptors. */
+	SetInterceptors(interceptors ...Interceptor)
+	/* Turn the request/reply logging of this driver on or off, see DefaultDebug. */
+	SetDebug(debug bool)
 
 	// Alerts
 	/* Dismiss current alert. */
@@ -250,6 +296,22 @@ type WebDriver interface {
 	ExecuteScriptAsyncRaw(script string, args []interface{}) ([]byte, error)
 }
 
+/* Web storage (localStorage or sessionStorage) of the current origin. */
+type Storage interface {
+	/* Value of key, empty string if the key is not set. */
+	Get(key string) (string, error)
+	/* Set value of key. */
+	Set(key, value string) error
+	/* Remove key. */
+	Remove(key string) error
+	/* All keys. */
+	Keys() ([]string, error)
+	/* Remove all keys. */
+	Clear() error
+	/* Number of keys. */
+	Size() (int, error)
+}
+
 type WebElement interface {
 	// Manipulation
 
@@ -257,6 +319,8 @@ type WebElement interface {
 	Click() error
 	/* Send keys (type) into element */
 	SendKeys(keys string) error
+	/* Send keys that must not show up in logs and traces, e.g. passwords */
+	SendSecretKeys(keys string) error
 	/* Submit */
 	Submit() error
 	/* Clear */
@@ -293,4 +357,6 @@ type WebElement interface {
 	Size() (*Size, error)
 	/* Get element CSS property value. */
 	CSSProperty(name string) (string, error)
+	/* PNG screenshot of the element. */
+	Screenshot() ([]byte, error)
 }
@@ -0,0 +1,206 @@
+package selenium
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/* Storage implementation, uses the JSON wire local_storage/session_storage endpoints and
+falls back to ExecuteScript once the server rejects them (e.g. W3C-only drivers). */
+type remoteStorage struct {
+	wd       *remoteWD
+	endpoint string // "local_storage" or "session_storage"
+	object   string // "localStorage" or "sessionStorage"
+	script   bool   // Set once the wire endpoints turned out to be unsupported, guarded by wd.mu.
+}
+
+type intReply struct {
+	Value  int
+	Status int
+}
+
+func (wd *remoteWD) LocalStorage() Storage {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	if wd.local == nil {
+		wd.local = &remoteStorage{wd: wd, endpoint: "local_storage", object: "localStorage"}
+	}
+	return wd.local
+}
+
+func (wd *remoteWD) SessionStorage() Storage {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	if wd.session == nil {
+		wd.session = &remoteStorage{wd: wd, endpoint: "session_storage", object: "sessionStorage"}
+	}
+	return wd.session
+}
+
+/* URL template of the storage endpoint, key is escaped for both the path and Sprintf. */
+func (s *remoteStorage) template(key ...string) string {
+	t := "/session/%s/" + s.endpoint
+	if len(key) > 0 {
+		t += "/key/" + strings.Replace(url.PathEscape(key[0]), "%", "%%", -1)
+	}
+	return t
+}
+
+/* Run the wire command, switch to the script fallback when the server does not know it. */
+func (s *remoteStorage) wire(command func() error) (bool, error) {
+	s.wd.mu.Lock()
+	script := s.script
+	s.wd.mu.Unlock()
+	if script {
+		return false, nil
+	}
+	err := command()
+	if err == nil {
+		return true, nil
+	}
+	if isUnsupported(err) {
+		s.wd.debugLog("%s endpoint unsupported, falling back to script: %s", s.endpoint, err)
+		s.wd.mu.Lock()
+		s.script = true
+		s.wd.mu.Unlock()
+		return false, nil
+	}
+	return true, err
+}
+
+func (s *remoteStorage) run(script string, args ...interface{}) (interface{}, error) {
+	return s.wd.ExecuteScript(strings.Replace(script, "STORAGE", "window."+s.object, -1), args)
+}
+
+func (s *remoteStorage) Get(key string) (value string, err error) {
+	done, err := s.wire(func() error {
+		response, err := s.wd.execute("GET", s.wd.requestURL(s.template(key), s.wd.SessionId()), nil)
+		if err != nil {
+			return err
+		}
+		reply := new(stringReply)
+		if err = json.Unmarshal(response, reply); err != nil {
+			return fmt.Errorf(`{"message":"%s"}`, err.Error())
+		}
+		if reply.Value != nil {
+			value = *reply.Value
+		}
+		return nil
+	})
+	if done {
+		return value, err
+	}
+
+	v, err := s.run("return STORAGE.getItem(arguments[0]);", key)
+	if err != nil || v == nil {
+		return "", err
+	}
+	return fmt.Sprint(v), nil
+}
+
+func (s *remoteStorage) Set(key, value string) error {
+	done, err := s.wire(func() error {
+		params := map[string]string{"key": key, "value": value}
+		return s.wd.voidCommand(s.template(), params)
+	})
+	if done {
+		return err
+	}
+
+	_, err = s.run("STORAGE.setItem(arguments[0], arguments[1]);", key, value)
+	return err
+}
+
+func (s *remoteStorage) Remove(key string) error {
+	done, err := s.wire(func() error {
+		_, err := s.wd.execute("DELETE", s.wd.requestURL(s.template(key), s.wd.SessionId()), nil)
+		return err
+	})
+	if done {
+		return err
+	}
+
+	_, err = s.run("STORAGE.removeItem(arguments[0]);", key)
+	return err
+}
+
+func (s *remoteStorage) Keys() (keys []string, err error) {
+	done, err := s.wire(func() error {
+		keys, err = s.wd.stringsCommand(s.template())
+		return err
+	})
+	if done {
+		return keys, err
+	}
+
+	v, err := s.run("var k = []; for (var i = 0; i < STORAGE.length; i++) { k.push(STORAGE.key(i)); } return k;")
+	if err != nil {
+		return nil, err
+	}
+	list, _ := v.([]interface{})
+	keys = make([]string, len(list))
+	for i, k := range list {
+		keys[i] = fmt.Sprint(k)
+	}
+	return keys, nil
+}
+
+func (s *remoteStorage) Clear() error {
+	done, err := s.wire(func() error {
+		_, err := s.wd.execute("DELETE", s.wd.requestURL(s.template(), s.wd.SessionId()), nil)
+		return err
+	})
+	if done {
+		return err
+	}
+
+	_, err = s.run("STORAGE.clear();")
+	return err
+}
+
+func (s *remoteStorage) Size() (size int, err error) {
+	done, err := s.wire(func() error {
+		response, err := s.wd.execute("GET", s.wd.requestURL(s.template()+"/size", s.wd.SessionId()), nil)
+		if err != nil {
+			return err
+		}
+		reply := new(intReply)
+		if err = json.Unmarshal(response, reply); err != nil {
+			return fmt.Errorf(`{"message":"%s"}`, err.Error())
+		}
+		size = reply.Value
+		return nil
+	})
+	if done {
+		return size, err
+	}
+
+	v, err := s.run("return STORAGE.length;")
+	if err != nil {
+		return 0, err
+	}
+	n, _ := v.(float64)
+	return int(n), nil
+}
+
+/* Check if err means the server does not implement the command: "unknown command" (status 9
+of JSON wire, error code of W3C), 405, or 404 without an error of its own (W3C servers also
+answer 404 for e.g. "no such element"). Other errors, e.g. 5xx after retries, are real. */
+func isUnsupported(err error) bool {
+	q, ok := err.(*QueryError)
+	if !ok {
+		return false
+	}
+	switch {
+	case q.Status == 9 || q.Code == "unknown command" || q.Code == "unknown method":
+		return true
+	case q.HTTPStatus == http.StatusMethodNotAllowed:
+		return true
+	case q.HTTPStatus == http.StatusNotFound:
+		return q.Status == SUCCESS && q.Code == ""
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package selenium
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+/* Only replies meaning "no such command" switch storage to the script fallback, other errors
+are returned and the endpoint is tried again on the next call. */
+func TestStorageFallback(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		code     int
+		ctype    string
+		body     string
+		fallback bool
+	}{
+		{"json wire unknown command", 500, JSON_MIME_TYPE, `{"status":9,"value":{"message":"unknown command"}}`, true},
+		{"w3c unknown command", 404, JSON_MIME_TYPE, `{"value":{"error":"unknown command","message":"Unknown command"}}`, true},
+		{"html 404", 404, "text/html", `<html>Not Found</html>`, true},
+		{"405", 405, "text/plain", `method not allowed`, true},
+		{"w3c no such window", 404, JSON_MIME_TYPE, `{"value":{"error":"no such window","message":"window closed"}}`, false},
+		{"json wire error", 500, JSON_MIME_TYPE, `{"status":13,"value":{"message":"boom"}}`, false},
+		{"gateway", 502, "text/html", `<html>Bad Gateway</html>`, false},
+		{"server failing mid reply", 500, "text/html", `<html>Internal Server Error</html>`, false},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/session":
+				fmt.Fprint(w, `{"sessionId":"s1","status":0,"value":{}}`)
+			case strings.HasSuffix(r.URL.Path, "/execute"):
+				w.Header().Set("Content-Type", JSON_MIME_TYPE)
+				fmt.Fprint(w, `{"sessionId":"s1","status":0,"value":"from script"}`)
+			default:
+				w.Header().Set("Content-Type", c.ctype)
+				w.WriteHeader(c.code)
+				fmt.Fprint(w, c.body)
+			}
+		}))
+
+		wd := quietDriver(t, srv.URL)
+		wd.SetRetryPolicy(RetryPolicy{Attempts: 2, Backoff: 1})
+		v, err := wd.LocalStorage().Get("k")
+		if c.fallback && (err != nil || v != "from script") {
+			t.Errorf("%s: got %q (%v), want the script fallback", c.name, v, err)
+		}
+		if !c.fallback && err == nil {
+			t.Errorf("%s: error swallowed, got %q", c.name, v)
+		}
+		if script := wd.(*remoteWD).local.script; script != c.fallback {
+			t.Errorf("%s: script fallback %v, want %v", c.name, script, c.fallback)
+		}
+		srv.Close()
+	}
+}
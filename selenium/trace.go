@@ -0,0 +1,166 @@
+package selenium
+
+import (
+	"encoding/json"
+	"fmt"
+	"gtf/drivers/log"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+/* Environment variable naming a file all drivers append their wire trace to. */
+const TraceEnv = "SE_TRACE"
+
+/* Replaces secret request bodies in logs and traces. */
+const redacted = `"[redacted]"`
+
+/* Base64 strings longer than this (screenshots, files) are truncated in traces. */
+const traceMaxBase64 = 256
+
+/* One line of a wire trace. */
+type TraceEntry struct {
+	Time     time.Time       `json:"time"`
+	Duration float64         `json:"durationMs"`
+	Session  string          `json:"session,omitempty"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Status   int             `json:"status,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+var (
+	base64Pattern = regexp.MustCompile(`^[A-Za-z0-9+/\r\n]+=*$`)
+	valueCommand  = regexp.MustCompile(`/element/([^/]+)/value$`)
+	envTrace      Interceptor
+	envTraceOnce  sync.Once
+)
+
+/* Interceptor writing every command and its reply to w as JSON lines, see TraceEntry.
+Screenshots and other base64 payloads are truncated. Secret commands (SendSecretKeys) and keys
+typed into password fields are redacted, finding out the type of an input costs one extra
+command per element. Enable it with wd.Use(TraceCommands(w)). */
+func TraceCommands(w io.Writer) Interceptor {
+	var mu sync.Mutex
+	passwords := map[string]bool{} // Element id to "is a password field".
+
+	return func(cmd *Command, next CommandHandler) (*Response, error) {
+		secret := cmd.Secret
+		if m := valueCommand.FindStringSubmatch(cmd.URL); m != nil && cmd.Method == "POST" && !secret {
+			mu.Lock()
+			password, known := passwords[m[1]]
+			mu.Unlock()
+			if !known {
+				password = isPasswordField(cmd, next)
+				mu.Lock()
+				passwords[m[1]] = password
+				mu.Unlock()
+			}
+			secret = password
+		}
+
+		start := time.Now()
+		res, err := next(cmd)
+
+		entry := TraceEntry{
+			Time:     start,
+			Duration: float64(time.Since(start)) / float64(time.Millisecond),
+			Method:   cmd.Method,
+			URL:      cmd.URL,
+		}
+		if cmd.wd != nil {
+			entry.Session = cmd.wd.SessionId()
+		}
+		if secret {
+			entry.Request = json.RawMessage(redacted)
+		} else if len(cmd.Body) > 0 {
+			entry.Request = traceBody(cmd.Body)
+		}
+		if res != nil {
+			entry.Status = res.StatusCode
+			entry.Response = traceBody(res.Body)
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		line, e := json.Marshal(entry)
+		if e == nil {
+			mu.Lock()
+			w.Write(append(line, '\n'))
+			mu.Unlock()
+		}
+		return res, err
+	}
+}
+
+/* Ask the rest of the chain for the type attribute of the element a value command types into. */
+func isPasswordField(cmd *Command, next CommandHandler) bool {
+	url := valueCommand.ReplaceAllString(cmd.URL, "/element/$1/attribute/type")
+	res, err := next(&Command{Method: "GET", URL: url, Driver: cmd.Driver, wd: cmd.wd})
+	if err != nil || res == nil {
+		return false
+	}
+	reply := new(stringReply)
+	return json.Unmarshal(res.Body, reply) == nil && reply.Value != nil && *reply.Value == "password"
+}
+
+/* Body as JSON with long base64 strings truncated, bodies that are not JSON become a string. */
+func traceBody(body []byte) json.RawMessage {
+	var v interface{}
+	if json.Unmarshal(body, &v) != nil {
+		v = truncateBase64(string(body))
+	} else {
+		v = truncateValue(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func truncateValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return truncateBase64(t)
+	case []interface{}:
+		for i := range t {
+			t[i] = truncateValue(t[i])
+		}
+	case map[string]interface{}:
+		for k := range t {
+			t[k] = truncateValue(t[k])
+		}
+	}
+	return v
+}
+
+func truncateBase64(s string) string {
+	if len(s) <= traceMaxBase64 || !base64Pattern.MatchString(s) {
+		return s
+	}
+	return fmt.Sprintf("%s...[%d bytes of base64]", s[:32], len(s))
+}
+
+/* Interceptor tracing to the file named by SE_TRACE, nil if the variable is not set. The file
+is shared by all drivers of the process. */
+func traceFromEnv() Interceptor {
+	path := os.Getenv(TraceEnv)
+	if path == "" {
+		return nil
+	}
+	envTraceOnce.Do(func() {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Warningf("%s: %s\n", TraceEnv, err)
+			return
+		}
+		envTrace = TraceCommands(f)
+	})
+	return envTrace
+}
@@ -0,0 +1,153 @@
+/* Helpers for go test:
+
+	func TestLogin(t *testing.T) {
+		p := setest.NewPage(t, "http://app.local/login")
+		p.TextBox(se.ById, "user").SetText("bob")
+		...
+	}
+
+The browser and the executor come from the -se.browser and -se.executor flags, or the
+SE_BROWSER and SE_EXECUTOR environment variables. Failed tests leave their artifacts in
+-se.artifacts (SE_ARTIFACTS), "artifacts" by default. */
+package setest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"se"
+	"se/selenium"
+	"se/visual"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	browser   = flag.String("se.browser", env("SE_BROWSER", "chrome"), "browser of the sessions started by setest")
+	executor  = flag.String("se.executor", env("SE_EXECUTOR", selenium.DEFAULT_EXECUTOR), "selenium server or grid hub url")
+	artifacts = flag.String("se.artifacts", env("SE_ARTIFACTS", "artifacts"), "directory of the artifacts of failed tests")
+)
+
+/* Time NewPage waits for a session of a pool. */
+const defaultAcquireTimeout = 5 * time.Minute
+
+func env(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+/* Open url in a new session, quit when the test ends. Elements that can't be found fail the
+test with t.Fatalf instead of panicking. */
+func NewPage(t testing.TB, url string, options ...se.PageOption) struct{ se.Page } {
+	t.Helper()
+	return NewPageWith(t, url, nil, options)
+}
+
+/* NewPage taking the session from pool if it is not nil, otherwise starting it with the
+capability functions applied. */
+func NewPageWith(t testing.TB, url string, pool *se.SessionPool, options []se.PageOption, params ...func(caps map[string]interface{})) struct{ se.Page } {
+	t.Helper()
+
+	options = append(options[:len(options):len(options)], se.OnLookupError(func(locator string, err error) {
+		t.Helper()
+		t.Fatalf("element %s not found: %s", locator, err)
+	}))
+
+	var p struct{ se.Page }
+	var err error
+	if pool != nil {
+		p, err = pool.OpenPage(url, defaultAcquireTimeout, options...)
+	} else {
+		caps := selenium.Capabilities{"browserName": *browser, "takesScreenshot": true}
+		for _, f := range params {
+			f(caps)
+		}
+		var wd selenium.WebDriver
+		wd, err = selenium.NewRemote(caps, *executor)
+		if err != nil {
+			t.Fatalf("starting %s on %s: %s", *browser, *executor, err)
+		}
+		p, err = se.OpenPageWith(url, wd, options)
+	}
+
+	page := &p.Page
+	if page.WebDriver() == nil {
+		t.Fatalf("opening %s: %s", url, err)
+	}
+	/* Cleanups run last-in first-out: artifacts are collected before the session ends. */
+	t.Cleanup(func() { page.Finish(t.Failed()) })
+	t.Cleanup(func() {
+		if t.Failed() {
+			SaveArtifacts(t, page)
+		}
+	})
+	if err != nil {
+		t.Fatalf("opening %s: %s", url, err)
+	}
+	return p
+}
+
+/* Write screenshot, page source, browser console and url of p to the artifacts directory of
+the test and log where they are. Errors are logged, the browser may be gone already. */
+func SaveArtifacts(t testing.TB, p *se.Page) {
+	t.Helper()
+
+	dir := filepath.Join(*artifacts, visual.FileName(t.Name()))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Logf("artifacts: %s", err)
+		return
+	}
+	save := func(name string, data []byte) {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Logf("artifacts: %s", err)
+			return
+		}
+		t.Logf("artifact: %s", path)
+	}
+
+	if url, err := p.URL(); err == nil {
+		t.Logf("url: %s", url)
+		save("url.txt", []byte(url+"\n"))
+	} else {
+		t.Logf("url: %s", err)
+	}
+	if png, err := p.ScreenshotPNG(); err == nil {
+		save("screenshot.png", png)
+	} else {
+		t.Logf("screenshot: %s", err)
+	}
+	if source, err := p.Source(); err == nil {
+		save("source.html", []byte(source))
+	} else {
+		t.Logf("page source: %s", err)
+	}
+	if console := consoleLog(p); console != "" {
+		t.Logf("console:\n%s", console)
+		save("console.txt", []byte(console))
+	}
+}
+
+/* Browser log entries and, if the page captures them, console events. */
+func consoleLog(p *se.Page) string {
+	var lines []string
+	if entries, err := p.WebDriver().Log(selenium.LogBrowser); err == nil {
+		for _, e := range entries {
+			lines = append(lines, fmt.Sprintf("%s %s %s", e.Timestamp.Format("15:04:05.000"), e.Level, e.Message))
+		}
+	}
+	if events, err := p.DrainConsole(); err == nil {
+		for _, e := range events {
+			lines = append(lines, fmt.Sprintf("%s %s %s", e.Timestamp.Format("15:04:05.000"), e.Level, e.Message))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,208 @@
+package se
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"se/selenium"
+	"strings"
+)
+
+/* Browser state saved by SaveState and restored by LoadState. */
+type State struct {
+	Cookies []selenium.Cookie `json:"cookies"`
+	Origins []OriginState     `json:"origins"`
+}
+
+/* Web storage content of one origin, e.g. "https://192.168.1.1". */
+type OriginState struct {
+	Origin         string            `json:"origin"`
+	LocalStorage   map[string]string `json:"localStorage"`
+	SessionStorage map[string]string `json:"sessionStorage"`
+}
+
+const storageDumpScript = `
+var dump = function(s) {
+	var m = {};
+	for (var i = 0; i < s.length; i++) {
+		var k = s.key(i);
+		m[k] = s.getItem(k);
+	}
+	return m;
+};
+return {local: dump(window.localStorage), session: dump(window.sessionStorage)};`
+
+const storageSeedScript = `
+var seed = function(s, m) {
+	for (var k in m) {
+		s.setItem(k, m[k]);
+	}
+};
+seed(window.localStorage, arguments[0]);
+seed(window.sessionStorage, arguments[1]);`
+
+/* Save cookies plus localStorage and sessionStorage of the current origin to a JSON file.
+If the file already holds a state, cookies and storage saved for other origins are kept, so
+calling SaveState on several origins of one application accumulates them in a single file.
+*/
+func (p *Page) SaveState(path string) error {
+	state := new(State)
+	if data, err := ioutil.ReadFile(path); err == nil {
+		if err = json.Unmarshal(data, state); err != nil {
+			return fmt.Errorf("invalid state file %s: %s", path, err)
+		}
+	}
+
+	cookies, err := p.webDriver.GetCookies()
+	if err != nil {
+		return err
+	}
+	state.Cookies = mergeCookies(state.Cookies, cookies)
+
+	origin, err := p.origin()
+	if err != nil {
+		return err
+	}
+	current, err := p.storageState(origin)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, o := range state.Origins {
+		if o.Origin == origin {
+			state.Origins[i], replaced = *current, true
+		}
+	}
+	if !replaced {
+		state.Origins = append(state.Origins, *current)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+/* Restore a state saved by SaveState and reload the page.
+Cookies and web storage can only be written by a document of the matching domain or
+origin, so the browser visits every saved origin before it comes back to the page url.
+*/
+func (p *Page) LoadState(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	state := new(State)
+	if err = json.Unmarshal(data, state); err != nil {
+		return fmt.Errorf("invalid state file %s: %s", path, err)
+	}
+
+	added := make([]bool, len(state.Cookies))
+	addCookies := func(location string) error {
+		u, err := url.Parse(location)
+		if err != nil {
+			return err
+		}
+		for i := range state.Cookies {
+			if added[i] || !domainMatch(u.Hostname(), state.Cookies[i].Domain) {
+				continue
+			}
+			if err := p.webDriver.AddCookie(&state.Cookies[i]); err != nil {
+				return err
+			}
+			added[i] = true
+		}
+		return nil
+	}
+
+	for _, o := range state.Origins {
+		if err = p.webDriver.Get(o.Origin); err != nil {
+			return err
+		}
+		args := []interface{}{o.LocalStorage, o.SessionStorage}
+		if _, err = p.webDriver.ExecuteScript(storageSeedScript, args); err != nil {
+			return err
+		}
+		if err = addCookies(o.Origin); err != nil {
+			return err
+		}
+	}
+
+	if err = p.webDriver.Get(p.url); err != nil {
+		return err
+	}
+	if err = addCookies(p.url); err != nil {
+		return err
+	}
+	return p.webDriver.Refresh()
+}
+
+/* Cookies of saved, with those of current added or replacing the saved cookie of the same
+name, domain and path. */
+func mergeCookies(saved, current []selenium.Cookie) []selenium.Cookie {
+	merged := append([]selenium.Cookie(nil), saved...)
+	for _, c := range current {
+		replaced := false
+		for i, s := range merged {
+			if s.Name == c.Name && s.Domain == c.Domain && s.Path == c.Path {
+				merged[i], replaced = c, true
+			}
+		}
+		if !replaced {
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
+
+/* Check if a cookie domain (".example.com" or "example.com") applies to host. */
+func domainMatch(host, domain string) bool {
+	if domain == "" {
+		return true
+	}
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	host = strings.ToLower(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+/* Origin (scheme://host[:port]) of the document currently loaded. */
+func (p *Page) origin() (string, error) {
+	current, err := p.webDriver.CurrentURL()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("no origin for url %q", current)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+func (p *Page) storageState(origin string) (*OriginState, error) {
+	reply, err := p.webDriver.ExecuteScript(storageDumpScript, nil)
+	if err != nil {
+		return nil, err
+	}
+	dump, _ := reply.(map[string]interface{})
+	return &OriginState{
+		Origin:         origin,
+		LocalStorage:   stringMap(dump["local"]),
+		SessionStorage: stringMap(dump["session"]),
+	}, nil
+}
+
+func stringMap(v interface{}) map[string]string {
+	m := map[string]string{}
+	if in, ok := v.(map[string]interface{}); ok {
+		for k, v := range in {
+			m[k] = fmt.Sprint(v)
+		}
+	}
+	return m
+}
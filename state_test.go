@@ -0,0 +1,144 @@
+package se
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"se/selenium"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/* JSON wire stub of one browser: sessions share its current url and cookie jar, scripts get
+the reply set in scripts for the first key they contain, null otherwise. */
+type browserStub struct {
+	mu      sync.Mutex
+	url     string
+	cookies []json.RawMessage // Cookie jar, as sent by AddCookie.
+	scripts map[string]string // Reply of the scripts containing the key.
+	run     []string          // Scripts executed.
+	nextID  int
+	quits   int // DELETE /session/:id received.
+}
+
+func newBrowserStub(t *testing.T) (*browserStub, string) {
+	b := &browserStub{scripts: map[string]string{}}
+	srv := httptest.NewServer(b)
+	t.Cleanup(srv.Close)
+	return b, srv.URL
+}
+
+func (b *browserStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w.Header().Set("Content-Type", selenium.JSON_MIME_TYPE)
+	if r.Method == "POST" && r.URL.Path == "/session" {
+		b.nextID++
+		fmt.Fprintf(w, `{"sessionId":"s%d","status":0,"value":{"browserName":"stub"}}`, b.nextID)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/session/"), "/", 2)
+	id, command := parts[0], ""
+	if len(parts) == 2 {
+		command = parts[1]
+	}
+	if r.Method == "DELETE" && command == "" {
+		b.quits++
+	}
+	var body struct {
+		Url    string
+		Script string
+		Cookie json.RawMessage
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	value := "null"
+	switch {
+	case command == "url" && r.Method == "POST":
+		b.url = body.Url
+	case command == "url":
+		value = fmt.Sprintf("%q", b.url)
+	case command == "cookie" && r.Method == "POST":
+		b.cookies = append(b.cookies, body.Cookie)
+	case command == "cookie" && r.Method == "DELETE":
+		b.cookies = nil
+	case command == "cookie":
+		value = "[" + string(joinRaw(b.cookies)) + "]"
+	case command == "element":
+		value = `{"ELEMENT":"e1"}`
+	case command == "execute":
+		b.run = append(b.run, body.Script)
+		for key, reply := range b.scripts {
+			if strings.Contains(body.Script, key) {
+				value = reply
+			}
+		}
+	}
+	fmt.Fprintf(w, `{"sessionId":%q,"status":0,"value":%s}`, id, value)
+}
+
+func joinRaw(values []json.RawMessage) []byte {
+	var out []string
+	for _, v := range values {
+		out = append(out, string(v))
+	}
+	return []byte(strings.Join(out, ","))
+}
+
+func openStubPage(t *testing.T, executor, url string, options ...PageOption) *Page {
+	t.Helper()
+	wd, err := selenium.NewRemote(selenium.Capabilities{"browserName": "stub"}, executor)
+	if err != nil {
+		t.Fatalf("new session: %s", err)
+	}
+	wd.SetDebug(false)
+	s, err := OpenPageWith(url, wd, options)
+	if err != nil {
+		t.Fatalf("open %s: %s", url, err)
+	}
+	return &s.Page
+}
+
+/* Session cookies have no expiry, they must not come back as expired in 1970. */
+func TestStateRoundTripSessionCookie(t *testing.T) {
+	b, executor := newBrowserStub(t)
+	b.cookies = []json.RawMessage{
+		json.RawMessage(`{"name":"sid","value":"1","path":"/","domain":"app.local","secure":false,"httpOnly":true}`),
+		json.RawMessage(`{"name":"remember","value":"2","path":"/","domain":"app.local","secure":false,"expiry":4102444800,"httpOnly":false}`),
+	}
+	b.scripts["dump"] = `{"local":{"theme":"dark"},"session":{}}`
+	p := openStubPage(t, executor, "http://app.local/home")
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := p.SaveState(path); err != nil {
+		t.Fatalf("save: %s", err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if n := strings.Count(string(data), `"expiry"`); n != 1 {
+		t.Errorf("state file has %d expiry fields, want 1:\n%s", n, data)
+	}
+
+	b.cookies = nil
+	if err := p.LoadState(path); err != nil {
+		t.Fatalf("load: %s", err)
+	}
+	if len(b.cookies) != 2 {
+		t.Fatalf("%d cookies restored, want 2", len(b.cookies))
+	}
+	for _, raw := range b.cookies {
+		var c map[string]interface{}
+		json.Unmarshal(raw, &c)
+		_, hasExpiry := c["expiry"]
+		if c["name"] == "sid" && hasExpiry {
+			t.Errorf("session cookie restored with an expiry: %s", raw)
+		}
+		if c["name"] == "remember" && c["expiry"] != float64(4102444800) {
+			t.Errorf("persistent cookie lost its expiry: %s", raw)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package se
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/* Action on a page or one of its elements (click, setText, navigate...), reported to the step
+observers of the page. */
+type Step struct {
+	Action   string
+	Locator  string   // Selector of the element, empty for page actions.
+	Args     []string // Text typed into secret elements is redacted.
+	Start    time.Time
+	Duration time.Duration
+	Err      error // Error returned, or panic raised, by the action.
+}
+
+func (s *Step) String() string {
+	desc := s.Action
+	if s.Locator != "" {
+		desc += " " + s.Locator
+	}
+	if len(s.Args) > 0 {
+		desc += " " + strings.Join(s.Args, ", ")
+	}
+	return desc
+}
+
+/* Notified before and after every step of a page. Observers may use the page (screenshots,
+url...) but must not run steps themselves. */
+type StepObserver interface {
+	BeforeStep(p *Page, s *Step)
+	AfterStep(p *Page, s *Step)
+}
+
+/* Report the steps of the page to o, e.g. a trace.Recorder. */
+func Observe(o StepObserver) PageOption {
+	return func(p *Page) {
+		p.observers = append(p.observers, o)
+	}
+}
+
+/* Report the steps of an already open page to o, see Observe. */
+func (p *Page) Observe(o StepObserver) {
+	p.observers = append(p.observers, o)
+}
+
+/* Run action as a step, panics (e.g. elements not found) are reported and raised again. */
+func (p *Page) step(action, locator string, args []string, run func() error) (err error) {
+	if len(p.initScripts) > 0 {
+		run = p.reinjectAfter(run)
+	}
+	if len(p.observers) == 0 {
+		return run()
+	}
+
+	s := &Step{Action: action, Locator: locator, Args: args, Start: time.Now()}
+	for _, o := range p.observers {
+		o.BeforeStep(p, s)
+	}
+	finished := false
+	defer func() {
+		r := recover()
+		s.Duration = time.Since(s.Start)
+		switch {
+		case r != nil:
+			s.Err = fmt.Errorf("panic: %v", r)
+		case !finished:
+			/* runtime.Goexit, e.g. t.Fatalf called by an OnLookupError handler. */
+			s.Err = errors.New("step aborted")
+		default:
+			s.Err = err
+		}
+		for _, o := range p.observers {
+			o.AfterStep(p, s)
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+	err = run()
+	finished = true
+	return err
+}
+
+/* Steps may load a new document without the page knowing, e.g. a click on a link or a form
+submit, instrument it like one loaded by Navigate. Errors are dropped: the document may not
+take scripts right now (alert open, still loading), the next step tries again. */
+func (p *Page) reinjectAfter(run func() error) func() error {
+	return func() error {
+		err := run()
+		p.reinjectScripts()
+		return err
+	}
+}
+
+/* Locator of the element in steps, e.g. "css selector=input#name". */
+func (e *Element) locator() string {
+	if e.selector == "" {
+		return ""
+	}
+	if e.selStrategy < len(elemSelector) && elemSelector[e.selStrategy] != "" {
+		return elemSelector[e.selStrategy] + "=" + e.selector
+	}
+	return e.selector
+}
+
+/* Typed text as step argument. */
+func (e *Element) textArg(text string) []string {
+	if e.secret {
+		return []string{"[redacted]"}
+	}
+	return []string{text}
+}
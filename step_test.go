@@ -0,0 +1,25 @@
+package se
+
+import (
+	"strings"
+	"testing"
+)
+
+type stepLog []string
+
+func (l *stepLog) BeforeStep(p *Page, s *Step) {}
+
+func (l *stepLog) AfterStep(p *Page, s *Step) {
+	*l = append(*l, strings.TrimSpace(s.Action+" "+s.Locator))
+}
+
+func TestFindElementAndClickIsAStep(t *testing.T) {
+	_, executor := newBrowserStub(t)
+	steps := new(stepLog)
+	p := openStubPage(t, executor, "http://app.local/", Observe(steps))
+
+	p.FindElementAndClick(ById, "save")
+	if got := strings.Join(*steps, ", "); got != "open, click id=save" {
+		t.Errorf("steps %q, want open, click id=save", got)
+	}
+}
@@ -0,0 +1,190 @@
+/* Step timeline of a test: every action of a page with screenshots before and after it, the url
+and a DOM snapshot, saved as a zip with an HTML viewer (unzip and open index.html).
+
+	rec := trace.New()
+	page, err := se.OpenPageWith(url, nil, []se.PageOption{se.Observe(rec)})
+	...
+	if failed {
+		rec.Save("login-trace.zip")
+	}
+*/
+package trace
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"se"
+	"sync"
+	"time"
+)
+
+/* Recorded step, file names are paths within the archive. */
+type Step struct {
+	Index    int       `json:"index"`
+	Action   string    `json:"action"`
+	Locator  string    `json:"locator,omitempty"`
+	Args     []string  `json:"args,omitempty"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"durationMs"`
+	Error    string    `json:"error,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Before   string    `json:"before,omitempty"` // Screenshot before the step.
+	After    string    `json:"after,omitempty"`  // Screenshot after the step.
+	DOM      string    `json:"dom,omitempty"`    // Page source after the step.
+}
+
+/* Step observer collecting the timeline in memory until Save. The zero value records steps
+without screenshots or DOM snapshots, see New. */
+type Recorder struct {
+	Screenshots bool // Take screenshots before and after every step.
+	DOM         bool // Save the page source after every step.
+
+	mu     sync.Mutex
+	steps  []Step
+	files  map[string][]byte
+	before []byte // Screenshot of the step in progress.
+}
+
+/* Recorder with screenshots and DOM snapshots turned on. */
+func New() *Recorder {
+	return &Recorder{Screenshots: true, DOM: true, files: map[string][]byte{}}
+}
+
+func (r *Recorder) BeforeStep(p *se.Page, s *se.Step) {
+	var shot []byte
+	if r.Screenshots {
+		shot, _ = p.ScreenshotPNG()
+	}
+	r.mu.Lock()
+	r.before = shot
+	r.mu.Unlock()
+}
+
+func (r *Recorder) AfterStep(p *se.Page, s *se.Step) {
+	step := Step{
+		Action:   s.Action,
+		Locator:  s.Locator,
+		Args:     s.Args,
+		Start:    s.Start,
+		Duration: float64(s.Duration) / float64(time.Millisecond),
+	}
+	if s.Err != nil {
+		step.Error = s.Err.Error()
+	}
+	/* The browser may not answer after a failed step (e.g. alert open), keep what we get. */
+	step.URL, _ = p.URL()
+	var after []byte
+	if r.Screenshots {
+		after, _ = p.ScreenshotPNG()
+	}
+	var dom string
+	if r.DOM {
+		dom, _ = p.Source()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.files == nil {
+		r.files = map[string][]byte{}
+	}
+	step.Index = len(r.steps) + 1
+	prefix := fmt.Sprintf("steps/%04d", step.Index)
+	if r.before != nil {
+		step.Before = prefix + "-before.png"
+		r.files[step.Before] = r.before
+		r.before = nil
+	}
+	if after != nil {
+		step.After = prefix + "-after.png"
+		r.files[step.After] = after
+	}
+	if dom != "" {
+		step.DOM = prefix + ".html"
+		r.files[step.DOM] = []byte(dom)
+	}
+	r.steps = append(r.steps, step)
+}
+
+/* Steps recorded so far. */
+func (r *Recorder) Steps() []Step {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Step(nil), r.steps...)
+}
+
+/* Forget the recorded steps. */
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps, r.files, r.before = nil, map[string][]byte{}, nil
+}
+
+/* Write the archive: trace.json, the files of the steps and the viewer. */
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	steps := r.steps
+	if steps == nil {
+		steps = []Step{}
+	}
+	data, err := json.MarshalIndent(steps, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	cw := &countWriter{w: w}
+	z := zip.NewWriter(cw)
+	add := func(name string, content []byte) {
+		if err != nil {
+			return
+		}
+		var f io.Writer
+		if f, err = z.Create(name); err == nil {
+			_, err = f.Write(content)
+		}
+	}
+	add("index.html", []byte(viewerHTML))
+	add("trace.json", data)
+	/* Browsers don't let file:// pages fetch trace.json, the viewer loads it as a script. */
+	add("trace.js", append(append([]byte("var TRACE = "), data...), ";\n"...))
+	for _, s := range steps {
+		for _, name := range []string{s.Before, s.After, s.DOM} {
+			if name != "" {
+				add(name, r.files[name])
+			}
+		}
+	}
+	if err != nil {
+		return cw.n, err
+	}
+	err = z.Close()
+	return cw.n, err
+}
+
+/* Write the archive to path. */
+func (r *Recorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,103 @@
+package trace
+
+/* Offline viewer of an unzipped archive, steps come from trace.js. */
+const viewerHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Test trace</title>
+<style>
+body { margin: 0; font: 13px sans-serif; display: flex; height: 100vh; }
+#steps { width: 320px; overflow-y: auto; border-right: 1px solid #ccc; margin: 0; padding: 0; list-style: none; }
+#steps li { padding: 6px 8px; border-bottom: 1px solid #eee; cursor: pointer; }
+#steps li.current { background: #dbe9ff; }
+#steps li.failed { color: #b00; }
+#steps .time { float: right; color: #888; }
+#main { flex: 1; display: flex; flex-direction: column; min-width: 0; }
+#details { padding: 8px; border-bottom: 1px solid #ccc; white-space: pre-wrap; }
+#tabs button { margin: 4px; }
+#tabs button.current { font-weight: bold; }
+#view { flex: 1; overflow: auto; background: #f4f4f4; }
+#view img { max-width: 100%; display: block; margin: 0 auto; }
+#view iframe { width: 100%; height: 100%; border: 0; background: #fff; }
+</style>
+</head>
+<body>
+<ul id="steps"></ul>
+<div id="main">
+<div id="details"></div>
+<div id="tabs">
+<button data-tab="before">Before</button>
+<button data-tab="after">After</button>
+<button data-tab="dom">DOM</button>
+<span>(arrow keys step through)</span>
+</div>
+<div id="view"></div>
+</div>
+<script src="trace.js"></script>
+<script>
+var current = 0, tab = "after";
+
+function text(s) { return document.createTextNode(s); }
+
+function show(i) {
+	if (!TRACE.length) { return; }
+	current = Math.max(0, Math.min(TRACE.length - 1, i));
+	var s = TRACE[current];
+	var items = document.querySelectorAll("#steps li");
+	for (var j = 0; j < items.length; j++) { items[j].className = (j == current ? "current " : "") + (TRACE[j].error ? "failed" : ""); }
+	items[current].scrollIntoView({block: "nearest"});
+
+	var d = document.getElementById("details");
+	d.textContent = "#" + s.index + " " + s.action + (s.locator ? " " + s.locator : "") +
+		(s.args ? " " + s.args.join(", ") : "") + "\n" + s.start + ", " + s.durationMs.toFixed(1) + " ms" +
+		(s.url ? "\n" + s.url : "") + (s.error ? "\nerror: " + s.error : "");
+
+	var buttons = document.querySelectorAll("#tabs button");
+	for (var j = 0; j < buttons.length; j++) { buttons[j].className = buttons[j].dataset.tab == tab ? "current" : ""; }
+
+	var v = document.getElementById("view");
+	v.innerHTML = "";
+	var file = s[tab];
+	if (!file) {
+		v.appendChild(text("Not recorded."));
+	} else if (tab == "dom") {
+		var f = document.createElement("iframe");
+		f.setAttribute("sandbox", "");
+		f.src = file;
+		v.appendChild(f);
+	} else {
+		var img = document.createElement("img");
+		img.src = file;
+		v.appendChild(img);
+	}
+}
+
+TRACE.forEach(function(s, i) {
+	var li = document.createElement("li");
+	var t = document.createElement("span");
+	t.className = "time";
+	t.textContent = s.durationMs.toFixed(0) + " ms";
+	li.appendChild(t);
+	li.appendChild(text(s.index + ". " + s.action + (s.locator ? " " + s.locator : "")));
+	li.onclick = function() { show(i); };
+	document.getElementById("steps").appendChild(li);
+});
+
+document.querySelectorAll("#tabs button").forEach(function(b) {
+	b.onclick = function() { tab = b.dataset.tab; show(current); };
+});
+
+document.onkeydown = function(e) {
+	if (e.key == "ArrowDown" || e.key == "ArrowRight") { show(current + 1); e.preventDefault(); }
+	if (e.key == "ArrowUp" || e.key == "ArrowLeft") { show(current - 1); e.preventDefault(); }
+};
+
+/* Start at the first failed step, the last one otherwise. */
+var failed = TRACE.findIndex(function(s) { return s.error; });
+show(failed >= 0 ? failed : TRACE.length - 1);
+if (!TRACE.length) { document.getElementById("details").textContent = "No steps recorded."; }
+</script>
+</body>
+</html>
+`
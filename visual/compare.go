@@ -0,0 +1,230 @@
+package visual
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+/* Comparison settings. */
+type Options struct {
+	Tolerance    float64  // Per-pixel color distance (0..1) still treated as equal, 0 means exact match.
+	AntiAliasing bool     // Tolerate pixels detected as anti-aliasing differences.
+	MaxMismatch  float64  // Mismatch percentage Check accepts.
+	Ignore       []Region // Regions left out of the comparison.
+}
+
+/* Comparison result. */
+type Result struct {
+	Name        string      // Test name, set by Store.Check.
+	DiffPixels  int         // Number of different pixels.
+	TotalPixels int         // Number of compared pixels (ignored regions excluded).
+	Mismatch    float64     // DiffPixels/TotalPixels in percent.
+	Diff        *image.RGBA // Diff image: differences red, anti-aliasing yellow, ignored regions blue.
+	Baseline    string      // Baseline file, set by Store.Check.
+	DiffFile    string      // Diff image file, set by Store.Check on mismatch.
+	Updated     bool        // The baseline was (re)written instead of compared.
+	Missing     bool        // There was no baseline to compare with, see MissingBaselineError.
+}
+
+var (
+	diffColor    = color.RGBA{255, 0, 0, 255}
+	aaColor      = color.RGBA{255, 255, 0, 255}
+	ignoredColor = color.RGBA{0, 0, 255, 64}
+)
+
+/* Compare two images pixel by pixel. Images of different sizes are compared over the larger
+bounds, pixels present in only one image count as differences. */
+func Compare(baseline, actual image.Image, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	var ignore []image.Rectangle
+	for _, r := range opts.Ignore {
+		rect, err := r.Rect()
+		if err != nil {
+			return nil, err
+		}
+		ignore = append(ignore, rect)
+	}
+
+	a, b := toNRGBA(baseline), toNRGBA(actual)
+	bounds := a.Bounds().Union(b.Bounds())
+	common := a.Bounds().Intersect(b.Bounds())
+	maxDelta := 35215 * opts.Tolerance * opts.Tolerance
+
+	res := &Result{Diff: image.NewRGBA(bounds)}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+	pixels:
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := image.Pt(x, y)
+			for _, r := range ignore {
+				if p.In(r) {
+					res.Diff.Set(x, y, ignoredColor)
+					continue pixels
+				}
+			}
+			res.TotalPixels++
+
+			if !p.In(common) {
+				res.DiffPixels++
+				res.Diff.Set(x, y, diffColor)
+				continue
+			}
+
+			delta := colorDelta(a, b, x, y, x, y, false)
+			switch {
+			case math.Abs(delta) <= maxDelta:
+				res.Diff.Set(x, y, faded(a.NRGBAAt(x, y)))
+			case opts.AntiAliasing && (antialiased(a, b, x, y) || antialiased(b, a, x, y)):
+				res.Diff.Set(x, y, aaColor)
+			default:
+				res.DiffPixels++
+				res.Diff.Set(x, y, diffColor)
+			}
+		}
+	}
+
+	if res.TotalPixels > 0 {
+		res.Mismatch = 100 * float64(res.DiffPixels) / float64(res.TotalPixels)
+	}
+	return res, nil
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	if n, ok := img.(*image.NRGBA); ok {
+		return n
+	}
+	n := image.NewNRGBA(img.Bounds())
+	draw.Draw(n, n.Bounds(), img, img.Bounds().Min, draw.Src)
+	return n
+}
+
+/* Gray, mostly transparent copy of an unchanged pixel, keeps the diff image readable. */
+func faded(c color.NRGBA) color.RGBA {
+	y := uint8(blend(rgb2y(float64(c.R), float64(c.G), float64(c.B)), 0.1*float64(c.A)/255))
+	return color.RGBA{y, y, y, 255}
+}
+
+func blend(c, a float64) float64 {
+	return 255 + (c-255)*a
+}
+
+func rgb2y(r, g, b float64) float64 { return r*0.29889531 + g*0.58662247 + b*0.11448223 }
+func rgb2i(r, g, b float64) float64 { return r*0.59597799 - g*0.27417610 - b*0.32180189 }
+func rgb2q(r, g, b float64) float64 { return r*0.21147017 - g*0.52261711 + b*0.31114694 }
+
+/* Perceptual color difference in YIQ space, see "Measuring perceived color difference using
+YIQ NTSC transmission color space in mobile applications" (Kotsarenko, Ramos). The sign tells
+whether the pixel got lighter or darker, yOnly returns the brightness difference only. */
+func colorDelta(a, b *image.NRGBA, x1, y1, x2, y2 int, yOnly bool) float64 {
+	c1, c2 := a.NRGBAAt(x1, y1), b.NRGBAAt(x2, y2)
+	if c1 == c2 {
+		return 0
+	}
+
+	a1, a2 := float64(c1.A)/255, float64(c2.A)/255
+	r1, g1, b1 := blend(float64(c1.R), a1), blend(float64(c1.G), a1), blend(float64(c1.B), a1)
+	r2, g2, b2 := blend(float64(c2.R), a2), blend(float64(c2.G), a2), blend(float64(c2.B), a2)
+
+	y := rgb2y(r1, g1, b1) - rgb2y(r2, g2, b2)
+	if yOnly {
+		return y
+	}
+	i := rgb2i(r1, g1, b1) - rgb2i(r2, g2, b2)
+	q := rgb2q(r1, g1, b1) - rgb2q(r2, g2, b2)
+
+	delta := 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+	if y > 0 {
+		return -delta
+	}
+	return delta
+}
+
+/* Check if the pixel at (x, y) of img is likely an anti-aliasing pixel, see "Anti-aliased
+Pixel and Intensity Slope Detector" (Vysniauskas, 2009). other is the image compared to. */
+func antialiased(img, other *image.NRGBA, x, y int) bool {
+	b := img.Bounds()
+	x0, y0 := max(x-1, b.Min.X), max(y-1, b.Min.Y)
+	x2, y2 := min(x+1, b.Max.X-1), min(y+1, b.Max.Y-1)
+
+	zeroes := 0
+	if x == x0 || x == x2 || y == y0 || y == y2 {
+		zeroes = 1
+	}
+	var lo, hi float64
+	var loX, loY, hiX, hiY int
+
+	for nx := x0; nx <= x2; nx++ {
+		for ny := y0; ny <= y2; ny++ {
+			if nx == x && ny == y {
+				continue
+			}
+			delta := colorDelta(img, img, x, y, nx, ny, true)
+			switch {
+			case delta == 0:
+				zeroes++
+				if zeroes > 2 {
+					return false
+				}
+			case delta < lo:
+				lo, loX, loY = delta, nx, ny
+			case delta > hi:
+				hi, hiX, hiY = delta, nx, ny
+			}
+		}
+	}
+
+	/* No darkest or brightest neighbour: not an intensity slope. */
+	if lo == 0 || hi == 0 {
+		return false
+	}
+
+	return (manySiblings(img, loX, loY) && manySiblings(other, loX, loY)) ||
+		(manySiblings(img, hiX, hiY) && manySiblings(other, hiX, hiY))
+}
+
+/* Check if the pixel has 3+ adjacent pixels of the same color. */
+func manySiblings(img *image.NRGBA, x, y int) bool {
+	b := img.Bounds()
+	if !image.Pt(x, y).In(b) {
+		return false
+	}
+	x0, y0 := max(x-1, b.Min.X), max(y-1, b.Min.Y)
+	x2, y2 := min(x+1, b.Max.X-1), min(y+1, b.Max.Y-1)
+
+	zeroes := 0
+	if x == x0 || x == x2 || y == y0 || y == y2 {
+		zeroes = 1
+	}
+	c := img.NRGBAAt(x, y)
+	for nx := x0; nx <= x2; nx++ {
+		for ny := y0; ny <= y2; ny++ {
+			if nx == x && ny == y {
+				continue
+			}
+			if img.NRGBAAt(nx, ny) == c {
+				zeroes++
+			}
+			if zeroes > 2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,280 @@
+/* Visual regression testing: screenshots are compared against baseline PNGs stored by test
+name. Everything is done in Go, no external image tools are needed. */
+package visual
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"se"
+)
+
+/* Environment variable that turns on update mode for stores created by NewStore. */
+const UpdateEnv = "SE_VISUAL_UPDATE"
+
+/* A region of the screenshot, in image pixels. */
+type Region interface {
+	Rect() (image.Rectangle, error)
+}
+
+type rectRegion image.Rectangle
+
+func (r rectRegion) Rect() (image.Rectangle, error) {
+	return image.Rectangle(r), nil
+}
+
+/* Fixed region. */
+func Rect(x, y, width, height int) Region {
+	return rectRegion(image.Rect(x, y, x+width, y+height))
+}
+
+type elementRegion struct {
+	elem  *se.Element
+	scale float64
+}
+
+func (r elementRegion) Rect() (image.Rectangle, error) {
+	location, err := r.elem.Location()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	size, err := r.elem.Size()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return image.Rect(
+		int(float64(location.X)*r.scale),
+		int(float64(location.Y)*r.scale),
+		int(float64(location.X+size.Width)*r.scale),
+		int(float64(location.Y+size.Height)*r.scale)), nil
+}
+
+/* Element region translated into the screenshot of origin. */
+type offsetRegion struct {
+	elementRegion
+	origin *se.Element
+}
+
+func (r offsetRegion) Rect() (image.Rectangle, error) {
+	rect, err := r.elementRegion.Rect()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	location, err := r.origin.Location()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return rect.Sub(image.Pt(int(float64(location.X)*r.scale), int(float64(location.Y)*r.scale))), nil
+}
+
+/* Element region translated into a screenshot of the viewport: element locations are relative
+to the document, the viewport starts at the scroll offset. */
+type viewportRegion struct {
+	elementRegion
+	page *se.Page
+}
+
+const scrollOffsetScript = `return [window.pageXOffset || 0, window.pageYOffset || 0];`
+
+func (r viewportRegion) Rect() (image.Rectangle, error) {
+	rect, err := r.elementRegion.Rect()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	v, err := r.page.WebDriver().ExecuteScript(scrollOffsetScript, nil)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	offset, _ := v.([]interface{})
+	if len(offset) != 2 {
+		return image.Rectangle{}, fmt.Errorf("unexpected scroll offset %v", v)
+	}
+	x, _ := offset[0].(float64)
+	y, _ := offset[1].(float64)
+	return rect.Sub(image.Pt(int(x*r.scale), int(y*r.scale))), nil
+}
+
+/* Copy of opts with the element regions moved into another screenshot by move. */
+func moveElementRegions(opts *Options, move func(r elementRegion) Region) *Options {
+	if opts == nil || len(opts.Ignore) == 0 {
+		return opts
+	}
+	moved := *opts
+	moved.Ignore = make([]Region, len(opts.Ignore))
+	for i, r := range opts.Ignore {
+		if er, ok := r.(elementRegion); ok {
+			r = move(er)
+		}
+		moved.Ignore[i] = r
+	}
+	return &moved
+}
+
+/* Region covered by an element, e.g. a clock or a counter that changes on every run.
+The element location is in CSS pixels relative to the document, scale is the device pixel
+ratio of the screenshot (1 on most desktop browsers). */
+func ElementRegion(e *se.Element, scale float64) Region {
+	if scale <= 0 {
+		scale = 1
+	}
+	return elementRegion{e, scale}
+}
+
+/* Baseline store. Baselines are kept in Dir as <name>.png; on mismatch the capture and the
+diff image are written next to them as <name>.actual.png and <name>.diff.png. */
+type Store struct {
+	Dir    string
+	Update bool // Rewrite baselines instead of comparing.
+}
+
+/* Store in dir, in update mode if $SE_VISUAL_UPDATE is set to a non-empty value. */
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir, Update: os.Getenv(UpdateEnv) != ""}
+}
+
+/* Returned by Check when the mismatch exceeds Options.MaxMismatch. */
+type MismatchError struct {
+	Result *Result
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("visual mismatch for %q: %.3f%% (%d of %d pixels) differ, see %s",
+		e.Result.Name, e.Result.Mismatch, e.Result.DiffPixels, e.Result.TotalPixels, e.Result.DiffFile)
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+/* Turn a test name such as "TestLogin/wrong password" into a file name, also used for the
+artifacts of setest. */
+func FileName(name string) string {
+	return unsafeChars.ReplaceAllString(name, "_")
+}
+
+/* Returned by Check outside update mode when name has no baseline. The capture is written as
+<name>.actual.png, run in update mode to accept it as the baseline. */
+type MissingBaselineError struct {
+	Result *Result
+	Actual string
+}
+
+func (e *MissingBaselineError) Error() string {
+	return fmt.Sprintf("no baseline %s for %q, capture written to %s", e.Result.Baseline, e.Result.Name, e.Actual)
+}
+
+/* Compare img against the baseline of name. In update mode the baseline is (re)written
+instead. */
+func (s *Store) Check(name string, img image.Image, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	base := filepath.Join(s.Dir, FileName(name))
+	baselineFile := base + ".png"
+
+	baseline, err := readPNG(baselineFile)
+	if !s.Update && os.IsNotExist(err) {
+		if err = os.MkdirAll(s.Dir, 0755); err != nil {
+			return nil, err
+		}
+		res := &Result{Name: name, Baseline: baselineFile, Missing: true}
+		if err = writePNG(base+".actual.png", img); err != nil {
+			return res, err
+		}
+		return res, &MissingBaselineError{res, base + ".actual.png"}
+	}
+	if s.Update {
+		if err = os.MkdirAll(s.Dir, 0755); err != nil {
+			return nil, err
+		}
+		if err = writePNG(baselineFile, img); err != nil {
+			return nil, err
+		}
+		os.Remove(base + ".actual.png")
+		os.Remove(base + ".diff.png")
+		return &Result{Name: name, Baseline: baselineFile, Updated: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := Compare(baseline, img, opts)
+	if err != nil {
+		return nil, err
+	}
+	res.Name, res.Baseline = name, baselineFile
+	if res.Mismatch <= opts.MaxMismatch {
+		return res, nil
+	}
+
+	res.DiffFile = base + ".diff.png"
+	if err = writePNG(base+".actual.png", img); err != nil {
+		return res, err
+	}
+	if err = writePNG(res.DiffFile, res.Diff); err != nil {
+		return res, err
+	}
+	return res, &MismatchError{res}
+}
+
+/* Check a screenshot of the page viewport, ElementRegion regions are moved by the scroll
+offset. */
+func (s *Store) CheckPage(p *se.Page, name string, opts *Options) (*Result, error) {
+	img, err := p.ScreenshotImage()
+	if err != nil {
+		return nil, err
+	}
+	opts = moveElementRegions(opts, func(r elementRegion) Region {
+		return viewportRegion{r, p}
+	})
+	return s.Check(name, img, opts)
+}
+
+/* Check a screenshot of the whole page, ElementRegion locations match it exactly. */
+func (s *Store) CheckFullPage(p *se.Page, name string, opts *Options) (*Result, error) {
+	img, err := p.FullScreenshotImage()
+	if err != nil {
+		return nil, err
+	}
+	return s.Check(name, img, opts)
+}
+
+/* Check a screenshot of an element. Rect regions are relative to the element, ElementRegion
+regions are moved by the element location. */
+func (s *Store) CheckElement(e *se.Element, name string, opts *Options) (*Result, error) {
+	data, err := e.Screenshot()
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	opts = moveElementRegions(opts, func(r elementRegion) Region {
+		return offsetRegion{r, e}
+	})
+	return s.Check(name, img, opts)
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
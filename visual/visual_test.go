@@ -0,0 +1,118 @@
+package visual
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/* w x h image, black left of edge and white from edge on, with the pixels set in marks. */
+func testImage(w, h, edge int, marks map[image.Point]color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{255, 255, 255, 255}
+			if x < edge {
+				c = color.NRGBA{0, 0, 0, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	for p, c := range marks {
+		img.Set(p.X, p.Y, c)
+	}
+	return img
+}
+
+func TestCompare(t *testing.T) {
+	base := testImage(10, 10, 5, nil)
+	offWhite := map[image.Point]color.Color{{7, 7}: color.NRGBA{250, 250, 250, 255}}
+	red := map[image.Point]color.Color{{7, 7}: color.NRGBA{255, 0, 0, 255}, {8, 2}: color.NRGBA{255, 0, 0, 255}}
+	/* Gray column between the black and the white half, as left by font smoothing. */
+	smoothed := map[image.Point]color.Color{}
+	for y := 0; y < 10; y++ {
+		smoothed[image.Pt(5, y)] = color.NRGBA{128, 128, 128, 255}
+	}
+
+	for _, c := range []struct {
+		name        string
+		actual      image.Image
+		opts        *Options
+		diff, total int
+	}{
+		{"identical", testImage(10, 10, 5, nil), nil, 0, 100},
+		{"exact match", testImage(10, 10, 5, offWhite), nil, 1, 100},
+		{"within tolerance", testImage(10, 10, 5, offWhite), &Options{Tolerance: 0.1}, 0, 100},
+		{"beyond tolerance", testImage(10, 10, 5, red), &Options{Tolerance: 0.1}, 2, 100},
+		{"anti-aliasing off", testImage(10, 10, 5, smoothed), nil, 10, 100},
+		{"anti-aliasing", testImage(10, 10, 5, smoothed), &Options{AntiAliasing: true}, 0, 100},
+		{"ignored region", testImage(10, 10, 5, red), &Options{Ignore: []Region{Rect(6, 6, 4, 4)}}, 1, 84},
+		{"ignored regions", testImage(10, 10, 5, red), &Options{Ignore: []Region{Rect(6, 6, 4, 4), Rect(8, 2, 1, 1)}}, 0, 83},
+		{"taller", testImage(10, 12, 5, nil), nil, 20, 120},
+		{"narrower", testImage(8, 10, 5, nil), nil, 20, 100},
+	} {
+		res, err := Compare(base, c.actual, c.opts)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if res.DiffPixels != c.diff || res.TotalPixels != c.total {
+			t.Errorf("%s: %d of %d pixels differ, want %d of %d", c.name, res.DiffPixels, res.TotalPixels, c.diff, c.total)
+		}
+		if want := 100 * float64(c.diff) / float64(c.total); res.Mismatch != want {
+			t.Errorf("%s: mismatch %.2f%%, want %.2f%%", c.name, res.Mismatch, want)
+		}
+		if res.Diff.Bounds() != base.Bounds().Union(c.actual.Bounds()) {
+			t.Errorf("%s: diff image bounds %v", c.name, res.Diff.Bounds())
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+/* Missing baseline: the check fails and leaves the capture, update mode accepts it. */
+func TestCheckBaselines(t *testing.T) {
+	dir := t.TempDir()
+	store := &Store{Dir: filepath.Join(dir, "baselines")}
+	name := "TestLogin/empty form"
+	base := filepath.Join(store.Dir, "TestLogin_empty_form")
+	img := testImage(10, 10, 5, nil)
+
+	res, err := store.Check(name, img, nil)
+	missing, ok := err.(*MissingBaselineError)
+	if !ok {
+		t.Fatalf("got %v, want a MissingBaselineError", err)
+	}
+	if !res.Missing || missing.Actual != base+".actual.png" || !exists(missing.Actual) || exists(base+".png") {
+		t.Errorf("missing baseline: result %+v, error %v", res, err)
+	}
+
+	store.Update = true
+	if res, err = store.Check(name, img, nil); err != nil || !res.Updated {
+		t.Fatalf("update: %+v (%v)", res, err)
+	}
+	if !exists(base+".png") || exists(base+".actual.png") {
+		t.Error("update mode did not replace the capture by a baseline")
+	}
+
+	store.Update = false
+	if res, err = store.Check(name, img, nil); err != nil || res.DiffPixels != 0 {
+		t.Errorf("same image: %+v (%v)", res, err)
+	}
+	changed := testImage(10, 10, 6, nil)
+	if _, err = store.Check(name, changed, &Options{MaxMismatch: 10}); err != nil {
+		t.Errorf("10%% change with MaxMismatch 10: %s", err)
+	}
+	res, err = store.Check(name, changed, nil)
+	if _, ok := err.(*MismatchError); !ok {
+		t.Fatalf("got %v, want a MismatchError", err)
+	}
+	if res.DiffFile != base+".diff.png" || !exists(res.DiffFile) || !exists(base+".actual.png") {
+		t.Errorf("mismatch: diff and capture not written, result %+v", res)
+	}
+}
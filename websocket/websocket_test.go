@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+/* Stub server running handle on the server side of every connection. */
+func stub(t *testing.T, handle func(c *Conn)) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			t.Errorf("upgrade: %s", err)
+			return
+		}
+		defer c.conn.Close()
+		handle(c)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dial(t *testing.T, url string) *Conn {
+	t.Helper()
+	c, err := Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %s", url, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestEcho(t *testing.T) {
+	url := stub(t, func(c *Conn) {
+		for {
+			data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			c.WriteMessage(data)
+		}
+	})
+	c := dial(t, url)
+
+	/* One message for each length encoding: 7 bits, 16 bits and 64 bits. */
+	for _, n := range []int{5, 300, 70000} {
+		sent := bytes.Repeat([]byte("x"), n)
+		if err := c.WriteMessage(sent); err != nil {
+			t.Fatalf("write %d bytes: %s", n, err)
+		}
+		got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %d bytes: %s", n, err)
+		}
+		if !bytes.Equal(got, sent) {
+			t.Errorf("echo of %d bytes: got %d bytes back", n, len(got))
+		}
+	}
+}
+
+func TestPingAndFragments(t *testing.T) {
+	url := stub(t, func(c *Conn) {
+		c.writeFrame(opPing, []byte("p"))
+		c.conn.Write([]byte{opText, 3, 'a', 'b', 'c'})
+		c.conn.Write([]byte{0x80 | opContinuation, 3, 'd', 'e', 'f'})
+		c.ReadMessage() // Skips the pong, returns once the client closes.
+	})
+	c := dial(t, url)
+
+	got, err := c.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "abcdef" {
+		t.Errorf("got %q, want %q", got, "abcdef")
+	}
+}
+
+func TestOversizedFrame(t *testing.T) {
+	url := stub(t, func(c *Conn) {
+		c.conn.Write([]byte{0x80 | opBinary, 127, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+		c.ReadMessage()
+	})
+	c := dial(t, url)
+
+	if _, err := c.ReadMessage(); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("got %v, want a frame size error", err)
+	}
+}
+
+func TestClosedByPeer(t *testing.T) {
+	url := stub(t, func(c *Conn) {
+		c.writeFrame(opClose, []byte{0x03, 0xe8})
+		c.ReadMessage()
+	})
+	c := dial(t, url)
+
+	if _, err := c.ReadMessage(); !IsClosed(err) {
+		t.Errorf("got %v, want a closed connection", err)
+	}
+}
+
+func TestHandshakeRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a websocket"))
+	}))
+	defer srv.Close()
+
+	if _, err := Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil); err == nil {
+		t.Error("dial succeeded without a 101 reply")
+	}
+}